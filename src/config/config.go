@@ -3,6 +3,7 @@ package config
 import (
 	_ "embed"
 	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
@@ -10,14 +11,99 @@ import (
 type Configuration struct {
 	Port string `envconfig:"PORT" required:"false" default:"8080"`
 
+	// RequestTimeout bounds the work behind an inbound request, except for
+	// sending the reply, which has TwilioTimeout of its own.
+	RequestTimeout time.Duration `envconfig:"REQUEST_TIMEOUT" required:"false" default:"10s"`
+
+	// LookupTimeout bounds the NPS lookup for an "alerts" text, so that a slow
+	// NPS leaves time to tell the texter it is unavailable.
+	LookupTimeout time.Duration `envconfig:"LOOKUP_TIMEOUT" required:"false" default:"8s"`
+
 	TwilioFromNumber string `envconfig:"TWILIO_FROM_NUMBER" required:"true"`
 	TwilioAccountSID string `envconfig:"TWILIO_ACCOUNT_SID" required:"true"`
 	TwilioAuthToken  string `envconfig:"TWILIO_AUTH_TOKEN" required:"true"`
 
+	// TwilioTimeout bounds each request to the Twilio API. A text that is
+	// being sent when the server shuts down gets this long to go out.
+	TwilioTimeout time.Duration `envconfig:"TWILIO_TIMEOUT" required:"false" default:"10s"`
+
+	// TwilioWebhookURL is the public base URL Twilio reaches the service at,
+	// such as https://alerts.example.com, used to check webhook signatures.
+	// Empty uses the host of each request.
+	TwilioWebhookURL string `envconfig:"TWILIO_WEBHOOK_URL" required:"false"`
+	// TwilioValidateSignature rejects webhooks without a valid
+	// X-Twilio-Signature. Only turn it off for local development.
+	TwilioValidateSignature bool `envconfig:"TWILIO_VALIDATE_SIGNATURE" required:"false" default:"true"`
+	// TwilioTwiMLReplies answers texts in the webhook response as a TwiML
+	// <Message> instead of sending them with a separate REST API call.
+	TwilioTwiMLReplies bool `envconfig:"TWILIO_TWIML_REPLIES" required:"false" default:"false"`
+
+	// SMSMaxSegments is how many SMS segments an alert text may take before
+	// its description is shortened. Zero sends alerts whole.
+	SMSMaxSegments int `envconfig:"SMS_MAX_SEGMENTS" required:"false" default:"3"`
+
+	// TemplatesDir holds reply templates that replace the built-in ones, such
+	// as alert.tmpl. Templates it does not have keep their built-in text.
+	TemplatesDir string `envconfig:"TEMPLATES_DIR" required:"false"`
+
+	// SessionIdleTimeout is how long "more" and "read" keep working after a
+	// texter last used their list of alerts. Zero never expires lists.
+	SessionIdleTimeout time.Duration `envconfig:"SESSION_IDLE_TIMEOUT" required:"false" default:"15m"`
+
+	// APIKeys, when set, are the keys the JSON API accepts in the X-API-Key
+	// header, separated by commas. Empty leaves the API open.
+	APIKeys []string `envconfig:"API_KEYS" required:"false"`
+	// APIRateLimit is how many JSON API requests a client can make a minute.
+	// Zero is no limit.
+	APIRateLimit int `envconfig:"API_RATE_LIMIT" required:"false" default:"60"`
+	// APITrustProxy counts anonymous JSON API clients by the address in the
+	// X-Forwarded-For or X-Real-IP header instead of the connection's. Only
+	// set it behind a proxy that sets those headers, or clients can pick
+	// their own address.
+	APITrustProxy bool `envconfig:"API_TRUST_PROXY" required:"false" default:"false"`
+
 	// ServiceHost is used in integration tests.
 	ServiceHost string `envconfig:"SERVICE_HOST" required:"false" default:"127.0.0.1"`
 
 	NPSApiKey string `envconfig:"NPS_API_KEY" required:"true"`
+
+	// NPSCacheTTL is how long NPS alert queries are cached. Zero disables the cache.
+	NPSCacheTTL time.Duration `envconfig:"NPS_CACHE_TTL" required:"false" default:"5m"`
+
+	// NPSCacheStatsInterval is how often the cache hit and miss counts are
+	// logged. Zero disables the log line.
+	NPSCacheStatsInterval time.Duration `envconfig:"NPS_CACHE_STATS_INTERVAL" required:"false" default:"1h"`
+
+	// NPSTimeout bounds each NPS request. The defaults keep a lookup with
+	// every retry, 3 x NPSTimeout plus 2 x NPSRetryMaxDelay, inside
+	// LookupTimeout.
+	NPSTimeout        time.Duration `envconfig:"NPS_TIMEOUT" required:"false" default:"2s"`
+	NPSMaxRetries     int           `envconfig:"NPS_MAX_RETRIES" required:"false" default:"2"`
+	NPSRetryBaseDelay time.Duration `envconfig:"NPS_RETRY_BASE_DELAY" required:"false" default:"250ms"`
+	NPSRetryMaxDelay  time.Duration `envconfig:"NPS_RETRY_MAX_DELAY" required:"false" default:"1s"`
+
+	// NPSBreakerThreshold is the number of consecutive failed NPS requests that
+	// opens the circuit breaker for NPSBreakerCooldown. Zero disables it.
+	NPSBreakerThreshold int           `envconfig:"NPS_BREAKER_THRESHOLD" required:"false" default:"5"`
+	NPSBreakerCooldown  time.Duration `envconfig:"NPS_BREAKER_COOLDOWN" required:"false" default:"30s"`
+
+	// NPSParksSnapshotPath is where the park directory is persisted between
+	// restarts. Empty keeps the directory in memory only.
+	NPSParksSnapshotPath string `envconfig:"NPS_PARKS_SNAPSHOT_PATH" required:"false"`
+	// NPSParksRefreshInterval is how often the park directory is refreshed
+	// from the NPS /parks endpoint. Zero disables refreshing.
+	NPSParksRefreshInterval time.Duration `envconfig:"NPS_PARKS_REFRESH_INTERVAL" required:"false" default:"24h"`
+
+	// StoreDriver picks where subscriptions and sent alerts are kept: memory,
+	// file (a JSON file) or sqlite. Empty uses file when StorePath is set and
+	// memory otherwise.
+	StoreDriver string `envconfig:"STORE_DRIVER" required:"false"`
+	// StorePath is the JSON file or SQLite database the store is kept in.
+	StorePath string `envconfig:"STORE_PATH" required:"false"`
+
+	// PollInterval is how often subscribed states and parks are checked for
+	// new alerts to text subscribers. Zero disables the poller.
+	PollInterval time.Duration `envconfig:"POLL_INTERVAL" required:"false" default:"5m"`
 }
 
 // LoadConfig loads environment variables with the prefix
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -13,3 +14,35 @@ func TestConfigMissingVars(t *testing.T) {
 
 	assert.EqualError(err, "required key TWILIO_FROM_NUMBER missing value")
 }
+
+func TestConfigDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("TWILIO_FROM_NUMBER", "+123456789")
+	t.Setenv("TWILIO_ACCOUNT_SID", "TEST_SID")
+	t.Setenv("TWILIO_AUTH_TOKEN", "TEST_TOKEN")
+	t.Setenv("NPS_API_KEY", "TEST_KEY")
+
+	cfg, err := LoadConfig()
+
+	assert.Nil(err)
+	assert.Equal("8080", cfg.Port)
+	assert.Equal(10*time.Second, cfg.RequestTimeout)
+	assert.Equal(8*time.Second, cfg.LookupTimeout)
+	assert.Equal(5*time.Minute, cfg.NPSCacheTTL)
+	assert.Equal(time.Hour, cfg.NPSCacheStatsInterval)
+	assert.Equal(2*time.Second, cfg.NPSTimeout)
+	assert.Equal(time.Second, cfg.NPSRetryMaxDelay)
+	assert.Equal(2, cfg.NPSMaxRetries)
+	assert.Equal(5, cfg.NPSBreakerThreshold)
+	assert.Equal(24*time.Hour, cfg.NPSParksRefreshInterval)
+	assert.Equal(5*time.Minute, cfg.PollInterval)
+	assert.Equal(10*time.Second, cfg.TwilioTimeout)
+	assert.True(cfg.TwilioValidateSignature)
+	assert.False(cfg.TwilioTwiMLReplies)
+	assert.Equal(3, cfg.SMSMaxSegments)
+	assert.Equal(15*time.Minute, cfg.SessionIdleTimeout)
+	assert.Empty(cfg.APIKeys)
+	assert.Equal(60, cfg.APIRateLimit)
+	assert.False(cfg.APITrustProxy)
+}
@@ -0,0 +1,171 @@
+// Package i18n translates replies into the languages texters can choose and
+// formats dates and durations for each of them.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported languages, as the ISO 639-1 codes saved in preferences.
+const (
+	English = "en"
+	Spanish = "es"
+)
+
+// Languages lists the supported languages, the default first.
+var Languages = []string{English, Spanish}
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalogs map the English text of each message, format verbs and all, to
+// its translation, keyed by language. English needs no catalog.
+var catalogs = func() map[string]map[string]string {
+	catalogs := map[string]map[string]string{}
+	for _, lang := range Languages[1:] {
+		content, err := catalogFiles.ReadFile("catalogs/" + lang + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("missing %s message catalog: %s", lang, err))
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			panic(fmt.Sprintf("invalid %s message catalog: %s", lang, err))
+		}
+		catalogs[lang] = catalog
+	}
+	return catalogs
+}()
+
+// languageWords maps the words texters use for each language to its code.
+var languageWords = map[string]string{
+	"en":      English,
+	"english": English,
+	"inglés":  English,
+	"ingles":  English,
+	"es":      Spanish,
+	"spanish": Spanish,
+	"español": Spanish,
+	"espanol": Spanish,
+}
+
+var languageNames = map[string]string{
+	English: "English",
+	Spanish: "Spanish",
+}
+
+// ParseLanguage resolves a user supplied word such as "es" or "español" to
+// a language.
+func ParseLanguage(s string) (string, bool) {
+	lang, ok := languageWords[strings.ToLower(strings.TrimSpace(s))]
+	return lang, ok
+}
+
+// Name returns the English name of lang, e.g. "Spanish", which can itself be
+// translated.
+func Name(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return languageNames[English]
+}
+
+// Lookup returns the translation of message into lang, if there is one.
+func Lookup(lang, message string) (string, bool) {
+	translated, ok := catalogs[lang][message]
+	return translated, ok
+}
+
+// Translate returns message in lang, or message itself when lang is English
+// or has no translation for it.
+func Translate(lang, message string) string {
+	if translated, ok := Lookup(lang, message); ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf translates format into lang and then formats it like fmt.Sprintf.
+func Sprintf(lang, format string, args ...interface{}) string {
+	return fmt.Sprintf(Translate(lang, format), args...)
+}
+
+var spanishMonths = [...]string{
+	"enero", "febrero", "marzo", "abril", "mayo", "junio",
+	"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre",
+}
+
+// FormatTime renders t, in its own location, as a date and time of day, e.g.
+// "Jun 7 at 10:55 AM PDT" or "7 de junio a las 10:55 PDT".
+func FormatTime(lang string, t time.Time) string {
+	if lang == Spanish {
+		return fmt.Sprintf("%d de %s a las %s", t.Day(), spanishMonths[t.Month()-1], t.Format("15:04 MST"))
+	}
+	return t.Format("Jan 2 at 3:04 PM MST")
+}
+
+// timeUnits are how each language phrases durations in the past.
+type timeUnits struct {
+	justNow string
+	ago     string
+	// singular and plural of minute, hour, day, month and year
+	units [5][2]string
+}
+
+var languageTimeUnits = map[string]timeUnits{
+	English: {
+		justNow: "just now",
+		ago:     "%s ago",
+		units: [5][2]string{
+			{"minute", "minutes"},
+			{"hour", "hours"},
+			{"day", "days"},
+			{"month", "months"},
+			{"year", "years"},
+		},
+	},
+	Spanish: {
+		justNow: "ahora mismo",
+		ago:     "hace %s",
+		units: [5][2]string{
+			{"minuto", "minutos"},
+			{"hora", "horas"},
+			{"día", "días"},
+			{"mes", "meses"},
+			{"año", "años"},
+		},
+	},
+}
+
+// Ago phrases a duration in the past the way a person would, e.g.
+// "3 hours ago" or "hace 3 horas".
+func Ago(lang string, d time.Duration) string {
+	units, ok := languageTimeUnits[lang]
+	if !ok {
+		units = languageTimeUnits[English]
+	}
+
+	var n, unit int
+	switch {
+	case d < time.Minute:
+		return units.justNow
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), 0
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), 1
+	case d < 30*24*time.Hour:
+		n, unit = int(d/(24*time.Hour)), 2
+	case d < 365*24*time.Hour:
+		n, unit = int(d/(30*24*time.Hour)), 3
+	default:
+		n, unit = int(d/(365*24*time.Hour)), 4
+	}
+
+	if n == 1 {
+		return fmt.Sprintf(units.ago, "1 "+units.units[unit][0])
+	}
+	return fmt.Sprintf(units.ago, fmt.Sprintf("%d %s", n, units.units[unit][1]))
+}
@@ -0,0 +1,107 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogsKeepFormatVerbs(t *testing.T) {
+	assert := assert.New(t)
+
+	for lang, catalog := range catalogs {
+		for message, translated := range catalog {
+			assert.Equal(formatVerb.FindAllString(message, -1), formatVerb.FindAllString(translated, -1), "%s: %q", lang, message)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("y", Translate(Spanish, "and"))
+	assert.Equal("and", Translate(English, "and"))
+	assert.Equal("UNTRANSLATED", Translate(Spanish, "UNTRANSLATED"))
+	assert.Equal("and", Translate("fr", "and"))
+}
+
+func TestSprintf(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Ya estás suscrito a las alertas del NPS para Yosemite.",
+		Sprintf(Spanish, "You're already subscribed to NPS alerts for %s.", "Yosemite"))
+	assert.Equal("You're already subscribed to NPS alerts for Yosemite.",
+		Sprintf(English, "You're already subscribed to NPS alerts for %s.", "Yosemite"))
+}
+
+func TestParseLanguage(t *testing.T) {
+	assert := assert.New(t)
+
+	for word, want := range map[string]string{
+		"es":       Spanish,
+		"Español":  Spanish,
+		"espanol":  Spanish,
+		" spanish": Spanish,
+		"EN":       English,
+		"inglés":   English,
+	} {
+		lang, ok := ParseLanguage(word)
+		assert.True(ok, word)
+		assert.Equal(want, lang, word)
+	}
+
+	_, ok := ParseLanguage("fr")
+	assert.False(ok)
+}
+
+func TestName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Spanish", Name(Spanish))
+	assert.Equal("English", Name(English))
+	assert.Equal("English", Name("fr"))
+}
+
+func TestFormatTime(t *testing.T) {
+	assert := assert.New(t)
+
+	loc := time.FixedZone("PDT", -7*60*60)
+	published := time.Date(2022, 6, 7, 22, 55, 0, 0, loc)
+
+	assert.Equal("Jun 7 at 10:55 PM PDT", FormatTime(English, published))
+	assert.Equal("7 de junio a las 22:55 PDT", FormatTime(Spanish, published))
+}
+
+func TestAgoEnglish(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("just now", Ago(English, -time.Minute))
+	assert.Equal("just now", Ago(English, 30*time.Second))
+	assert.Equal("1 minute ago", Ago(English, time.Minute))
+	assert.Equal("45 minutes ago", Ago(English, 45*time.Minute))
+	assert.Equal("1 hour ago", Ago(English, 90*time.Minute))
+	assert.Equal("2 days ago", Ago(English, 50*time.Hour))
+	assert.Equal("3 months ago", Ago(English, 95*24*time.Hour))
+	assert.Equal("2 years ago", Ago(English, 800*24*time.Hour))
+}
+
+func TestAgoSpanish(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("ahora mismo", Ago(Spanish, 30*time.Second))
+	assert.Equal("hace 1 minuto", Ago(Spanish, time.Minute))
+	assert.Equal("hace 3 horas", Ago(Spanish, 3*time.Hour))
+	assert.Equal("hace 1 día", Ago(Spanish, 30*time.Hour))
+	assert.Equal("hace 3 meses", Ago(Spanish, 95*24*time.Hour))
+	assert.Equal("hace 2 años", Ago(Spanish, 800*24*time.Hour))
+}
+
+func TestAgoUnknownLanguage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("3 hours ago", Ago("fr", 3*time.Hour))
+}
@@ -0,0 +1,24 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx that carries logger, so packages further
+// down the call chain log with the same request scoped fields.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or a no-op logger when there
+// is none.
+func FromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*zap.Logger); ok && logger != nil {
+		return logger
+	}
+	return zap.NewNop()
+}
@@ -0,0 +1,33 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	assert := assert.New(t)
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core).With(zap.String("requestID", "TEST_REQUEST_ID"))
+
+	ctx := WithLogger(context.Background(), logger)
+
+	FromContext(ctx).Info("TEST_MESSAGE")
+
+	assert.Equal(logs.All()[0].Message, "TEST_MESSAGE")
+	assert.Equal(logs.All()[0].ContextMap()["requestID"], "TEST_REQUEST_ID")
+}
+
+func TestFromContextMissingLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := FromContext(context.Background())
+
+	assert.NotNil(logger)
+	assert.NotPanics(func() { logger.Info("TEST_MESSAGE") })
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/WilliamDeBruin/nps_alerts/src/config"
 	"github.com/WilliamDeBruin/nps_alerts/src/server"
@@ -22,5 +26,10 @@ func main() {
 		logger.Fatal(fmt.Sprintf("failed to initialize server: %s", err))
 	}
 
-	srv.Serve()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := srv.Serve(ctx); err != nil {
+		logger.Fatal(err.Error())
+	}
 }
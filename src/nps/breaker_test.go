@@ -0,0 +1,72 @@
+package nps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newCircuitBreaker(2, time.Minute)
+
+	assert.True(b.allow())
+	b.failure()
+	assert.True(b.allow())
+	b.failure()
+	assert.False(b.allow())
+}
+
+func TestCircuitBreakerSuccessResets(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newCircuitBreaker(2, time.Minute)
+
+	b.failure()
+	b.success()
+	b.failure()
+
+	assert.True(b.allow())
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.failure()
+	assert.False(b.allow())
+
+	now = now.Add(2 * time.Minute)
+
+	// only one probe is let through while half open
+	assert.True(b.allow())
+	assert.False(b.allow())
+
+	// a failed probe restarts the cooldown
+	b.failure()
+	assert.False(b.allow())
+
+	now = now.Add(2 * time.Minute)
+
+	assert.True(b.allow())
+	b.success()
+	assert.True(b.allow())
+	assert.True(b.allow())
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newCircuitBreaker(0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		b.failure()
+	}
+
+	assert.True(b.allow())
+}
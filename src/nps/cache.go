@@ -0,0 +1,244 @@
+package nps
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// fetchTimeout bounds a fetch shared by concurrent callers, which runs
+	// apart from their contexts. It leaves room for the retries of the
+	// wrapped client.
+	fetchTimeout = 15 * time.Second
+
+	// maxStale is how long an expired entry is kept to be served when the
+	// upstream query fails, before it is swept.
+	maxStale = time.Hour
+
+	// maxEntries caps the cache. The entries closest to expiring are dropped
+	// first when it is full.
+	maxEntries = 1000
+)
+
+// CacheStats counts how alert queries were answered by a CachingClient.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// Stale counts expired entries served because the upstream query failed.
+	Stale uint64
+}
+
+// CachingClient wraps a Client and caches alert queries for a fixed TTL.
+// Concurrent identical queries share a single upstream request, and expired
+// entries are served when the upstream query fails.
+type CachingClient struct {
+	client Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	group singleflight.Group
+
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	lastSweep time.Time
+
+	hits   uint64
+	misses uint64
+	stale  uint64
+}
+
+type cacheEntry struct {
+	alerts  []AlertDetails
+	expires time.Time
+}
+
+// NewCachingClient returns a Client that caches the results of client for ttl.
+func NewCachingClient(client Client, ttl time.Duration) *CachingClient {
+	return &CachingClient{
+		client:  client,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// GetAlert returns the most recent alert for the given state. It returns an
+// error when the state has no alerts.
+func (c *CachingClient) GetAlert(ctx context.Context, stateCode string) (*AlertDetails, error) {
+
+	alerts, err := c.GetAlerts(ctx, stateCode, &AlertOptions{MaxResults: 1})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(alerts) == 0 {
+		return nil, errorf(ErrNoAlerts, "no alerts found for state code %s", stateCode)
+	}
+
+	return &alerts[0], nil
+}
+
+func (c *CachingClient) GetAlerts(ctx context.Context, stateCode string, opts *AlertOptions) ([]AlertDetails, error) {
+	stateCode = normalizeStateCodes(stateCode)
+	return c.get(ctx, "state:"+stateCode, opts, func(ctx context.Context, o *AlertOptions) ([]AlertDetails, error) {
+		return c.client.GetAlerts(ctx, stateCode, o)
+	})
+}
+
+func (c *CachingClient) GetParkAlerts(ctx context.Context, park string, opts *AlertOptions) ([]AlertDetails, error) {
+	return c.get(ctx, "park:"+strings.ToLower(strings.TrimSpace(park)), opts, func(ctx context.Context, o *AlertOptions) ([]AlertDetails, error) {
+		return c.client.GetParkAlerts(ctx, park, o)
+	})
+}
+
+func (c *CachingClient) SetTransport(transport http.RoundTripper) {
+	c.client.SetTransport(transport)
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *CachingClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Stale:  atomic.LoadUint64(&c.stale),
+	}
+}
+
+// get answers a query from the cache, or runs fetch once for every concurrent
+// caller asking for the same key. The shared fetch runs on its own context,
+// bounded by fetchTimeout, so one caller giving up does not fail the others,
+// while every caller stops waiting when its own context is done. Entries
+// hold every alert for the key, so category filters and result limits are
+// applied on the way out, and the fetch leaves the page size to the wrapped
+// client rather than use whichever caller came first.
+func (c *CachingClient) get(ctx context.Context, key string, opts *AlertOptions, fetch func(context.Context, *AlertOptions) ([]AlertDetails, error)) ([]AlertDetails, error) {
+
+	if opts == nil {
+		opts = &AlertOptions{}
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && c.now().Before(entry.expires) {
+		atomic.AddUint64(&c.hits, 1)
+		return applyOptions(entry.alerts, opts), nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	logger := logging.FromContext(ctx)
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logger), fetchTimeout)
+		defer cancel()
+
+		alerts, err := fetch(fetchCtx, &AlertOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		c.put(key, alerts)
+
+		return alerts, nil
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if err := res.Err; err != nil {
+		if ok {
+			atomic.AddUint64(&c.stale, 1)
+			return applyOptions(entry.alerts, opts), nil
+		}
+		return nil, err
+	}
+
+	return applyOptions(res.Val.([]AlertDetails), opts), nil
+}
+
+// put caches alerts under key, first sweeping entries that have been
+// expired for longer than maxStale, at most once a TTL, and making room when
+// the cache is full.
+func (c *CachingClient) put(key string, alerts []AlertDetails) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	if now.Sub(c.lastSweep) >= c.ttl {
+		for k, e := range c.entries {
+			if now.Sub(e.expires) > maxStale {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxEntries {
+		oldest := ""
+		for k, e := range c.entries {
+			if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+
+	c.entries[key] = cacheEntry{
+		alerts:  alerts,
+		expires: now.Add(c.ttl),
+	}
+}
+
+// normalizeStateCodes upper-cases and sorts a comma separated list of state
+// codes, so "nv,CA" and "CA,NV" are cached as one query.
+func normalizeStateCodes(stateCodes string) string {
+	codes := strings.Split(stateCodes, ",")
+	for i, code := range codes {
+		codes[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, ",")
+}
+
+// applyOptions filters cached alerts by category and caps the result count,
+// returning a copy so callers cannot modify the cache.
+func applyOptions(alerts []AlertDetails, opts *AlertOptions) []AlertDetails {
+
+	result := make([]AlertDetails, 0, len(alerts))
+
+	for _, a := range alerts {
+		if opts.MaxResults > 0 && len(result) == opts.MaxResults {
+			break
+		}
+		if len(opts.Categories) > 0 && !hasCategory(opts.Categories, a.Category) {
+			continue
+		}
+		result = append(result, a)
+	}
+
+	return result
+}
+
+func hasCategory(categories []string, category string) bool {
+	for _, c := range categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
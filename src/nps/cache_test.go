@@ -0,0 +1,277 @@
+package nps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClient struct {
+	alerts  []AlertDetails
+	err     error
+	calls   uint64
+	release chan struct{}
+
+	lastStateCode string
+	lastOpts      *AlertOptions
+	lastCtxErr    error
+}
+
+func (f *fakeClient) GetAlert(ctx context.Context, stateCode string) (*AlertDetails, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeClient) GetAlerts(ctx context.Context, stateCode string, opts *AlertOptions) ([]AlertDetails, error) {
+	atomic.AddUint64(&f.calls, 1)
+	f.lastStateCode = stateCode
+	f.lastOpts = opts
+	if f.release != nil {
+		<-f.release
+	}
+	f.lastCtxErr = ctx.Err()
+	return f.alerts, f.err
+}
+
+func (f *fakeClient) GetParkAlerts(ctx context.Context, park string, opts *AlertOptions) ([]AlertDetails, error) {
+	return f.GetAlerts(ctx, park, opts)
+}
+
+func (f *fakeClient) SetTransport(http.RoundTripper) {}
+
+func TestCachingClientHitAndMiss(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{
+		alerts: []AlertDetails{{AlertHeader: "NEWEST"}, {AlertHeader: "OLDEST"}},
+	}
+
+	c := NewCachingClient(inner, time.Minute)
+
+	alerts, err := c.GetAlerts(context.Background(), "CA", nil)
+	assert.Nil(err)
+	assert.Len(alerts, 2)
+
+	alerts, err = c.GetAlerts(context.Background(), "ca", &AlertOptions{MaxResults: 1})
+	assert.Nil(err)
+	assert.Equal([]AlertDetails{{AlertHeader: "NEWEST"}}, alerts)
+
+	alert, err := c.GetAlert(context.Background(), "CA")
+	assert.Nil(err)
+	assert.Equal("NEWEST", alert.AlertHeader)
+
+	assert.Equal(uint64(1), inner.calls)
+	assert.Equal(CacheStats{Hits: 2, Misses: 1}, c.Stats())
+}
+
+func TestCachingClientExpires(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{alerts: []AlertDetails{{AlertHeader: "TEST_HEADER"}}}
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	c := NewCachingClient(inner, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, _ = c.GetAlerts(context.Background(), "CA", nil)
+	now = now.Add(2 * time.Minute)
+	_, _ = c.GetAlerts(context.Background(), "CA", nil)
+
+	assert.Equal(uint64(2), inner.calls)
+	assert.Equal(CacheStats{Misses: 2}, c.Stats())
+}
+
+func TestCachingClientServesStaleOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{alerts: []AlertDetails{{AlertHeader: "TEST_HEADER"}}}
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	c := NewCachingClient(inner, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, _ = c.GetParkAlerts(context.Background(), "yose", nil)
+
+	now = now.Add(2 * time.Minute)
+	inner.err = errors.New("TEST_UPSTREAM_ERR")
+
+	alerts, err := c.GetParkAlerts(context.Background(), "yose", nil)
+
+	assert.Nil(err)
+	assert.Equal([]AlertDetails{{AlertHeader: "TEST_HEADER"}}, alerts)
+	assert.Equal(uint64(1), c.Stats().Stale)
+
+	alerts, err = c.GetParkAlerts(context.Background(), "glac", nil)
+
+	assert.Nil(alerts)
+	assert.EqualError(err, "TEST_UPSTREAM_ERR")
+}
+
+func TestCachingClientFiltersCategories(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{
+		alerts: []AlertDetails{
+			{AlertHeader: "BEARS", Category: CategoryDanger},
+			{AlertHeader: "ROAD CLOSED", Category: CategoryClosure},
+		},
+	}
+
+	c := NewCachingClient(inner, time.Minute)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{Categories: []string{"park closure"}})
+
+	assert.Nil(err)
+	assert.Equal([]AlertDetails{{AlertHeader: "ROAD CLOSED", Category: CategoryClosure}}, alerts)
+}
+
+func TestCachingClientFetchesWithDefaultOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{alerts: []AlertDetails{{AlertHeader: "TEST_HEADER"}}}
+
+	c := NewCachingClient(inner, time.Minute)
+
+	_, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{PageSize: 2, MaxResults: 1})
+
+	assert.Nil(err)
+	assert.Equal(&AlertOptions{}, inner.lastOpts)
+}
+
+func TestCachingClientCoalescesConcurrentRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{
+		alerts:  []AlertDetails{{AlertHeader: "TEST_HEADER"}},
+		release: make(chan struct{}),
+	}
+
+	c := NewCachingClient(inner, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			alerts, err := c.GetAlerts(context.Background(), "CA", nil)
+			assert.Nil(err)
+			assert.Len(alerts, 1)
+		}()
+	}
+
+	// wait until every caller has missed the cache before releasing the fetch
+	for atomic.LoadUint64(&c.misses) < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	assert.Equal(uint64(1), atomic.LoadUint64(&inner.calls))
+}
+
+func TestCachingClientNormalizesStateCodes(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{alerts: []AlertDetails{{AlertHeader: "TEST_HEADER"}}}
+
+	c := NewCachingClient(inner, time.Minute)
+
+	_, _ = c.GetAlerts(context.Background(), "NV,CA", nil)
+	_, _ = c.GetAlerts(context.Background(), "ca, nv", nil)
+
+	assert.Equal(uint64(1), inner.calls)
+	assert.Equal("CA,NV", inner.lastStateCode)
+}
+
+func TestCachingClientSweepsExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{alerts: []AlertDetails{{AlertHeader: "TEST_HEADER"}}}
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	c := NewCachingClient(inner, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, _ = c.GetAlerts(context.Background(), "CA", nil)
+	_, _ = c.GetAlerts(context.Background(), "NV", nil)
+
+	now = now.Add(maxStale + 2*time.Minute)
+	_, _ = c.GetAlerts(context.Background(), "NV", nil)
+
+	assert.Len(c.entries, 1)
+	assert.Contains(c.entries, "state:NV")
+}
+
+func TestCachingClientBounded(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{alerts: []AlertDetails{{AlertHeader: "TEST_HEADER"}}}
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	c := NewCachingClient(inner, time.Hour)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i <= maxEntries; i++ {
+		now = now.Add(time.Millisecond)
+		_, _ = c.GetParkAlerts(context.Background(), fmt.Sprintf("park%d", i), nil)
+	}
+
+	assert.Len(c.entries, maxEntries)
+	assert.NotContains(c.entries, "park:park0")
+	assert.Contains(c.entries, fmt.Sprintf("park:park%d", maxEntries))
+}
+
+func TestCachingClientCancelledCallerDoesNotFailOthers(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := &fakeClient{
+		alerts:  []AlertDetails{{AlertHeader: "TEST_HEADER"}},
+		release: make(chan struct{}),
+	}
+
+	c := NewCachingClient(inner, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.GetAlerts(ctx, "CA", nil)
+		first <- err
+	}()
+
+	for atomic.LoadUint64(&inner.calls) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	type result struct {
+		alerts []AlertDetails
+		err    error
+	}
+	second := make(chan result)
+	go func() {
+		alerts, err := c.GetAlerts(context.Background(), "CA", nil)
+		second <- result{alerts, err}
+	}()
+
+	for atomic.LoadUint64(&c.misses) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the caller that started the fetch gives up
+	cancel()
+	assert.ErrorIs(<-first, context.Canceled)
+
+	close(inner.release)
+	res := <-second
+
+	assert.Nil(res.err)
+	assert.Len(res.alerts, 1)
+	assert.Nil(inner.lastCtxErr)
+	assert.Equal(uint64(1), atomic.LoadUint64(&inner.calls))
+}
@@ -0,0 +1,403 @@
+package nps
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"go.uber.org/zap"
+)
+
+//go:embed parks.json
+var parksDetailsContent []byte
+
+const (
+	parksURL = "https://developer.nps.gov/api/v1/parks"
+
+	parksFields = "fullName,parkCode,name,designation,states,latitude,longitude,url"
+)
+
+// designations expands the unit designation codes used in the embedded park
+// catalog.
+var designations = map[string]string{
+	"NP":    "National Park",
+	"NM":    "National Monument",
+	"NHS":   "National Historic Site",
+	"NHP":   "National Historical Park",
+	"NMEM":  "National Memorial",
+	"NPRES": "National Preserve",
+	"NRA":   "National Recreation Area",
+	"NB":    "National Battlefield",
+	"NS":    "National Seashore",
+	"NMP":   "National Military Park",
+	"NL":    "National Lakeshore",
+	"PKWY":  "Parkway",
+	"NBP":   "National Battlefield Park",
+	"NST":   "National Scenic Trail",
+}
+
+// Park is a unit of the National Park Service.
+type Park struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	FullName    string   `json:"fullName"`
+	Designation string   `json:"designation,omitempty"`
+	States      []string `json:"states"`
+	Latitude    float64  `json:"latitude,omitempty"`
+	Longitude   float64  `json:"longitude,omitempty"`
+	URL         string   `json:"url,omitempty"`
+}
+
+// parkDetails is a park in the embedded parks.json catalog.
+type parkDetails struct {
+	UnitName        string   `json:"unitName,omitempty"`
+	UnitCode        string   `json:"unitCode,omitempty"`
+	UnitDesignation string   `json:"unitDesignation,omitempty"`
+	State           []string `json:"state,omitempty"`
+	EstDate         string   `json:"estDate,omitempty"`
+}
+
+type parksResponse struct {
+	Total string    `json:"total,omitempty"`
+	Limit string    `json:"limit,omitempty"`
+	Start string    `json:"start,omitempty"`
+	Data  []npsPark `json:"data,omitempty"`
+}
+
+type npsPark struct {
+	ParkCode    string `json:"parkCode,omitempty"`
+	Name        string `json:"name,omitempty"`
+	FullName    string `json:"fullName,omitempty"`
+	Designation string `json:"designation,omitempty"`
+	States      string `json:"states,omitempty"`
+	Latitude    string `json:"latitude,omitempty"`
+	Longitude   string `json:"longitude,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// Directory is the catalog of NPS parks. It starts from a snapshot on disk
+// when one exists, or the catalog embedded in the binary, and can refresh
+// itself from the NPS /parks endpoint.
+type Directory struct {
+	apiKey       string
+	httpClient   *http.Client
+	snapshotPath string
+
+	mu       sync.RWMutex
+	registry *Registry
+	updated  time.Time
+}
+
+// NewDirectory returns a Directory loaded from the snapshot at snapshotPath,
+// falling back to the embedded catalog when there is no usable snapshot. An
+// empty snapshotPath disables persistence.
+func NewDirectory(apiKey, snapshotPath string) (*Directory, error) {
+
+	d := &Directory{
+		apiKey:       apiKey,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		snapshotPath: snapshotPath,
+	}
+
+	if parks, updated, err := d.loadSnapshot(); err == nil {
+		d.set(parks, updated)
+		return d, nil
+	}
+
+	parks, err := embeddedParks()
+	if err != nil {
+		return nil, err
+	}
+	d.set(parks, time.Time{})
+
+	return d, nil
+}
+
+// Park returns the park with the given code, ignoring case.
+func (d *Directory) Park(code string) (Park, bool) {
+	return d.Registry().Park(code)
+}
+
+// Find resolves a park code or name, tolerating typos and a trailing
+// designation such as "national park".
+func (d *Directory) Find(query string) (Park, bool) {
+	p, _, ok := d.Registry().Resolve(query)
+	return p, ok
+}
+
+// Resolve is Find with the closest parks to suggest when query does not
+// resolve to a single park.
+func (d *Directory) Resolve(query string) (Park, []Park, bool) {
+	return d.Registry().Resolve(query)
+}
+
+// Parks returns every park, sorted by code.
+func (d *Directory) Parks() []Park {
+	return d.Registry().Parks()
+}
+
+// Registry returns the index over the current catalog. A refresh swaps in a
+// new Registry, so callers holding the old one keep a consistent view.
+func (d *Directory) Registry() *Registry {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.registry
+}
+
+// Updated returns when the catalog was last refreshed from NPS, or the zero
+// time when it is still the embedded catalog.
+func (d *Directory) Updated() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.updated
+}
+
+func (d *Directory) SetTransport(transport http.RoundTripper) {
+	d.httpClient.Transport = transport
+}
+
+// Refresh replaces the catalog with the parks currently listed by the NPS
+// /parks endpoint and writes a snapshot when persistence is enabled. The
+// current catalog is kept when the request fails.
+func (d *Directory) Refresh(ctx context.Context) error {
+
+	parks, err := d.fetchParks(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(parks) == 0 {
+		return errors.New("nps api returned no parks")
+	}
+
+	now := time.Now()
+	d.set(parks, now)
+
+	return d.saveSnapshot(parks)
+}
+
+// Run refreshes the catalog every interval until ctx is done. The first
+// refresh happens straight away unless the catalog is younger than interval.
+func (d *Directory) Run(ctx context.Context, interval time.Duration) {
+
+	logger := logging.FromContext(ctx)
+
+	refresh := func() {
+		if err := d.Refresh(ctx); err != nil {
+			if ctx.Err() == nil {
+				logger.Error("failed to refresh park directory", zap.Error(err))
+			}
+			return
+		}
+		logger.Info("refreshed park directory", zap.Int("parks", len(d.Parks())))
+	}
+
+	if time.Since(d.Updated()) >= interval {
+		refresh()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+func (d *Directory) set(parks []Park, updated time.Time) {
+	registry := NewRegistry(parks)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.registry = registry
+	d.updated = updated
+}
+
+func (d *Directory) fetchParks(ctx context.Context) ([]Park, error) {
+
+	parks := []Park{}
+
+	for {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(defaultPageSize))
+		q.Set("start", strconv.Itoa(len(parks)))
+		q.Set("fields", parksFields)
+
+		req, err := http.NewRequestWithContext(ctx, "GET", parksURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.URL.RawQuery = q.Encode()
+		req.Header.Add("x-api-key", d.apiKey)
+
+		page, err := d.fetchParksPage(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range page.Data {
+			parks = append(parks, p.toPark())
+		}
+
+		total, err := strconv.Atoi(page.Total)
+		if err != nil || len(page.Data) == 0 || len(parks) >= total {
+			return parks, nil
+		}
+	}
+}
+
+func (d *Directory) fetchParksPage(req *http.Request) (*parksResponse, error) {
+
+	res, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err, ErrUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+
+	page := &parksResponse{}
+	if err := json.NewDecoder(res.Body).Decode(page); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+
+	return page, nil
+}
+
+func (d *Directory) loadSnapshot() ([]Park, time.Time, error) {
+
+	if d.snapshotPath == "" {
+		return nil, time.Time{}, errors.New("snapshots are disabled")
+	}
+
+	info, err := os.Stat(d.snapshotPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	content, err := ioutil.ReadFile(d.snapshotPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	parks := []Park{}
+	if err := json.Unmarshal(content, &parks); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if len(parks) == 0 {
+		return nil, time.Time{}, errors.New("snapshot has no parks")
+	}
+
+	return parks, info.ModTime(), nil
+}
+
+// saveSnapshot writes parks to a temporary file first so a crash cannot
+// leave a truncated snapshot behind.
+func (d *Directory) saveSnapshot(parks []Park) error {
+
+	if d.snapshotPath == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(parks, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(d.snapshotPath), filepath.Base(d.snapshotPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), d.snapshotPath)
+}
+
+func embeddedParks() ([]Park, error) {
+
+	details := []parkDetails{}
+	if err := json.Unmarshal(parksDetailsContent, &details); err != nil {
+		return nil, err
+	}
+
+	parks := make([]Park, 0, len(details))
+	for _, p := range details {
+		parks = append(parks, p.toPark())
+	}
+	return parks, nil
+}
+
+func (p parkDetails) toPark() Park {
+	designation, ok := designations[p.UnitDesignation]
+	if !ok && p.UnitDesignation != "Other" {
+		designation = p.UnitDesignation
+	}
+
+	fullName := p.UnitName
+	if designation != "" && !strings.HasSuffix(fullName, designation) {
+		fullName = fullName + " " + designation
+	}
+
+	return Park{
+		Code:        p.UnitCode,
+		Name:        p.UnitName,
+		FullName:    fullName,
+		Designation: designation,
+		States:      p.State,
+	}
+}
+
+func (p npsPark) toPark() Park {
+	states := []string{}
+	for _, s := range strings.Split(p.States, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			states = append(states, strings.ToUpper(s))
+		}
+	}
+
+	latitude, _ := strconv.ParseFloat(p.Latitude, 64)
+	longitude, _ := strconv.ParseFloat(p.Longitude, 64)
+
+	return Park{
+		Code:        p.ParkCode,
+		Name:        p.Name,
+		FullName:    p.FullName,
+		Designation: p.Designation,
+		States:      states,
+		Latitude:    latitude,
+		Longitude:   longitude,
+		URL:         p.URL,
+	}
+}
@@ -0,0 +1,257 @@
+package nps
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parksTransport serves the given parks from a fake /parks endpoint, one page
+// per request.
+type parksTransport struct {
+	parks    []npsPark
+	status   int
+	requests []*http.Request
+}
+
+func (m *parksTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.requests = append(m.requests, req)
+
+	status := m.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	start, _ := strconv.Atoi(req.URL.Query().Get("start"))
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+
+	end := start + limit
+	if end > len(m.parks) {
+		end = len(m.parks)
+	}
+
+	body, _ := json.Marshal(parksResponse{
+		Total: strconv.Itoa(len(m.parks)),
+		Start: strconv.Itoa(start),
+		Data:  m.parks[start:end],
+	})
+
+	return &http.Response{
+		Header:     make(http.Header),
+		Request:    req,
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+	}, nil
+}
+
+var testParks = []npsPark{
+	{
+		ParkCode:    "yell",
+		Name:        "Yellowstone",
+		FullName:    "Yellowstone National Park",
+		Designation: "National Park",
+		States:      "ID,MT,WY",
+		Latitude:    "44.59824417",
+		Longitude:   "-110.5471695",
+		URL:         "https://www.nps.gov/yell/index.htm",
+	},
+	{
+		ParkCode:    "newp",
+		Name:        "Brand New",
+		FullName:    "Brand New National Park",
+		Designation: "National Park",
+		States:      "CA",
+	},
+}
+
+func TestNewDirectoryEmbedded(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := NewDirectory("TEST_KEY", "")
+
+	assert.Nil(err)
+	assert.Len(d.Parks(), 411)
+	assert.True(d.Updated().IsZero())
+
+	p, ok := d.Park("YOSE")
+	assert.True(ok)
+	assert.Equal(Park{
+		Code:        "yose",
+		Name:        "Yosemite",
+		FullName:    "Yosemite National Park",
+		Designation: "National Park",
+		States:      []string{"CA"},
+	}, p)
+
+	p, ok = d.Park("alag")
+	assert.True(ok)
+	assert.Equal("Alagnak National Wild and Scenic River", p.FullName)
+}
+
+func TestDirectoryFind(t *testing.T) {
+	assert := assert.New(t)
+
+	d, _ := NewDirectory("TEST_KEY", "")
+
+	p, ok := d.Find(" Yosemite National Park ")
+	assert.True(ok)
+	assert.Equal("yose", p.Code)
+
+	p, ok = d.Find("yosemite")
+	assert.True(ok)
+	assert.Equal("yose", p.Code)
+
+	_, ok = d.Find("not a park")
+	assert.False(ok)
+}
+
+func TestDirectoryRefresh(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "parks.json")
+
+	d, _ := NewDirectory("TEST_KEY", path)
+
+	transport := &parksTransport{parks: testParks}
+	d.SetTransport(transport)
+
+	err := d.Refresh(context.Background())
+
+	assert.Nil(err)
+	assert.Len(d.Parks(), 2)
+	assert.False(d.Updated().IsZero())
+	assert.Equal("TEST_KEY", transport.requests[0].Header.Get("x-api-key"))
+
+	p, ok := d.Park("yell")
+	assert.True(ok)
+	assert.Equal(Park{
+		Code:        "yell",
+		Name:        "Yellowstone",
+		FullName:    "Yellowstone National Park",
+		Designation: "National Park",
+		States:      []string{"ID", "MT", "WY"},
+		Latitude:    44.59824417,
+		Longitude:   -110.5471695,
+		URL:         "https://www.nps.gov/yell/index.htm",
+	}, p)
+
+	// a new directory starts from the snapshot written by the refresh
+	reloaded, err := NewDirectory("TEST_KEY", path)
+
+	assert.Nil(err)
+	assert.Equal(d.Parks(), reloaded.Parks())
+	assert.False(reloaded.Updated().IsZero())
+}
+
+func TestDirectoryRefreshPaginates(t *testing.T) {
+	assert := assert.New(t)
+
+	parks := []npsPark{}
+	for i := 0; i < 120; i++ {
+		parks = append(parks, npsPark{ParkCode: "p" + strconv.Itoa(i), Name: "Park " + strconv.Itoa(i)})
+	}
+
+	d, _ := NewDirectory("TEST_KEY", "")
+
+	transport := &parksTransport{parks: parks}
+	d.SetTransport(transport)
+
+	err := d.Refresh(context.Background())
+
+	assert.Nil(err)
+	assert.Len(d.Parks(), 120)
+	assert.Len(transport.requests, 3)
+}
+
+func TestDirectoryRefreshFailureKeepsCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	d, _ := NewDirectory("TEST_KEY", "")
+	d.SetTransport(&parksTransport{status: http.StatusInternalServerError})
+
+	err := d.Refresh(context.Background())
+
+	assert.ErrorIs(err, ErrUnavailable)
+	assert.Len(d.Parks(), 411)
+
+	d.SetTransport(&parksTransport{})
+
+	err = d.Refresh(context.Background())
+
+	assert.EqualError(err, "nps api returned no parks")
+	assert.Len(d.Parks(), 411)
+}
+
+func TestNewDirectoryCorruptSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "parks.json")
+	_ = os.WriteFile(path, []byte("{not json"), 0o644)
+
+	d, err := NewDirectory("TEST_KEY", path)
+
+	assert.Nil(err)
+	assert.Len(d.Parks(), 411)
+}
+
+func TestDirectoryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	d, _ := NewDirectory("TEST_KEY", "")
+	transport := &parksTransport{parks: testParks}
+	d.SetTransport(transport)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	// the embedded catalog is stale, so Run refreshes straight away
+	assert.Eventually(func() bool { return !d.Updated().IsZero() }, time.Second, time.Millisecond)
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after the context was cancelled")
+	}
+}
+
+func TestClientUsesDirectory(t *testing.T) {
+	assert := assert.New(t)
+
+	d, _ := NewDirectory("TEST_KEY", "")
+	d.SetTransport(&parksTransport{parks: testParks})
+	_ = d.Refresh(context.Background())
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "newp", Title: "TEST_TITLE", LastIndexedDate: "2022-08-02 12:34:45.6"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY", WithDirectory(d))
+	c.SetTransport(transport)
+
+	alerts, err := c.GetParkAlerts(context.Background(), "brand new", nil)
+
+	assert.Nil(err)
+	assert.Equal("newp", transport.requests[0].URL.Query().Get("parkCode"))
+	assert.Equal("Brand New", alerts[0].FullParkName)
+	assert.Equal("California", alerts[0].FullStateName)
+}
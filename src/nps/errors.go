@@ -0,0 +1,97 @@
+package nps
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrInvalidState is returned when a state code is not in the list of
+	// known state codes.
+	ErrInvalidState = errors.New("invalid state code")
+
+	// ErrUnknownPark is returned when a park code or name does not match any
+	// park in the catalog.
+	ErrUnknownPark = errors.New("unknown park")
+
+	// ErrNoAlerts is returned by GetAlert when there are no alerts to return.
+	// GetAlerts and GetParkAlerts return an empty slice instead.
+	ErrNoAlerts = errors.New("no alerts found")
+
+	// ErrRateLimited is returned when the NPS API keeps answering 429 Too Many
+	// Requests after retries.
+	ErrRateLimited = errors.New("nps api rate limit exceeded")
+
+	// ErrUnavailable is returned when the NPS API cannot be reached, keeps
+	// failing after retries, or the circuit breaker is open.
+	ErrUnavailable = errors.New("nps api is unavailable")
+)
+
+// StatusError is returned when the NPS API answers with a status other than
+// 200 OK. A 429 matches ErrRateLimited and a 5xx matches ErrUnavailable.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("nps api responded with status %d", e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnavailable:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// DecodeError is returned when an NPS API response body cannot be decoded.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode nps api response: %s", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownParkError is returned when a park query does not resolve to a
+// single park. It matches ErrUnknownPark and carries the closest parks, if
+// any, so callers can ask "did you mean".
+type UnknownParkError struct {
+	Query       string
+	Suggestions []Park
+}
+
+func (e *UnknownParkError) Error() string {
+	return fmt.Sprintf("cannot find a park matching %q", e.Query)
+}
+
+func (e *UnknownParkError) Is(target error) bool {
+	return target == ErrUnknownPark
+}
+
+// detailedError gives a sentinel error a more descriptive message while
+// keeping it matchable with errors.Is.
+type detailedError struct {
+	msg string
+	err error
+}
+
+func (e *detailedError) Error() string {
+	return e.msg
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.err
+}
+
+func errorf(sentinel error, format string, args ...interface{}) error {
+	return &detailedError{msg: fmt.Sprintf(format, args...), err: sentinel}
+}
@@ -0,0 +1,87 @@
+package nps
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rawTransport struct {
+	body string
+}
+
+func (m *rawTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Header:     make(http.Header),
+		Request:    req,
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(m.body)),
+	}, nil
+}
+
+func TestStatusErrorIs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.ErrorIs(&StatusError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited)
+	assert.False(errors.Is(&StatusError{StatusCode: http.StatusTooManyRequests}, ErrUnavailable))
+
+	assert.ErrorIs(&StatusError{StatusCode: http.StatusBadGateway}, ErrUnavailable)
+	assert.False(errors.Is(&StatusError{StatusCode: http.StatusBadGateway}, ErrRateLimited))
+
+	assert.False(errors.Is(&StatusError{StatusCode: http.StatusForbidden}, ErrUnavailable))
+	assert.EqualError(&StatusError{StatusCode: http.StatusForbidden}, "nps api responded with status 403")
+}
+
+func TestInvalidStateError(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(&mockTransport{})
+
+	_, err := c.GetAlerts(context.Background(), "MV", nil)
+
+	assert.ErrorIs(err, ErrInvalidState)
+	assert.False(errors.Is(err, ErrUnknownPark))
+}
+
+func TestNoAlertsError(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(&mockTransport{responseBody: alertResponse{Total: "0"}})
+
+	_, err := c.GetAlert(context.Background(), "MT")
+
+	assert.ErrorIs(err, ErrNoAlerts)
+}
+
+func TestDecodeError(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(&rawTransport{body: "<html>not json</html>"})
+
+	_, err := c.GetAlerts(context.Background(), "MT", nil)
+
+	var decodeErr *DecodeError
+	assert.True(errors.As(err, &decodeErr))
+	assert.Contains(err.Error(), "cannot decode nps api response")
+}
+
+func TestUpstreamStatusError(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(&sequenceTransport{responses: []mockResponse{{status: http.StatusUnauthorized}}})
+
+	_, err := c.GetParkAlerts(context.Background(), "yose", nil)
+
+	var statusErr *StatusError
+	assert.True(errors.As(err, &statusErr))
+	assert.Equal(http.StatusUnauthorized, statusErr.StatusCode)
+}
@@ -1,11 +1,14 @@
 package nps
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,24 +16,107 @@ import (
 //go:embed state_codes.json
 var stateCodesContent []byte
 
-//go:embed parks.json
-var parksDetailsContent []byte
-
 const (
 	baseURL = "https://developer.nps.gov/api/v1/alerts"
 
 	alertsUrl = "https://www.nps.gov/planyourvisit/alerts.htm?s=%s&p=1&v=0"
+
+	parkAlertsUrl = "https://www.nps.gov/%s/planyourvisit/conditions.htm"
+
+	// defaultPageSize is the number of alerts requested per page when walking
+	// the NPS pagination. The API caps limit at 50.
+	defaultPageSize = 50
+
+	// maxAlertPages bounds the pages fetched for one query, in case NPS keeps
+	// answering with full pages.
+	maxAlertPages = 20
 )
 
+// Alert categories as reported by the NPS API.
+const (
+	CategoryDanger      = "Danger"
+	CategoryCaution     = "Caution"
+	CategoryInformation = "Information"
+	CategoryClosure     = "Park Closure"
+)
+
+// categoryAliases maps the words users type to NPS alert categories.
+var categoryAliases = map[string]string{
+	"danger":       CategoryDanger,
+	"dangers":      CategoryDanger,
+	"caution":      CategoryCaution,
+	"cautions":     CategoryCaution,
+	"info":         CategoryInformation,
+	"information":  CategoryInformation,
+	"closure":      CategoryClosure,
+	"closures":     CategoryClosure,
+	"closed":       CategoryClosure,
+	"park closure": CategoryClosure,
+}
+
+// ParseCategory resolves a user supplied word such as "closures" or "danger"
+// to an NPS alert category.
+func ParseCategory(s string) (string, bool) {
+	category, ok := categoryAliases[strings.ToLower(strings.TrimSpace(s))]
+	return category, ok
+}
+
 type fetcher struct {
 	apiKey     string
 	httpClient *http.Client
-	stateCodes map[string]string
-	parks      *[]parkDetails
+	parks      *Directory
+	retry      retryPolicy
+	breaker    *circuitBreaker
+}
+
+// Option configures optional behaviour of a Client created by NewClient.
+type Option func(*fetcher)
+
+// WithTimeout sets the timeout for each HTTP request to the NPS API. Values
+// of zero or less keep the default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(f *fetcher) {
+		if timeout > 0 {
+			f.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// WithRetries sets how many times a request is retried after a transport
+// error, a 429 or a 5xx response. Delays grow exponentially from baseDelay up
+// to maxDelay with jitter. A Retry-After header longer than maxDelay is not
+// waited for.
+func WithRetries(maxRetries int, baseDelay, maxDelay time.Duration) Option {
+	return func(f *fetcher) {
+		f.retry = retryPolicy{
+			maxRetries: maxRetries,
+			baseDelay:  baseDelay,
+			maxDelay:   maxDelay,
+		}
+	}
+}
+
+// WithDirectory sets the park directory used to resolve park codes and
+// names. By default each client uses its own copy of the embedded catalog.
+func WithDirectory(directory *Directory) Option {
+	return func(f *fetcher) {
+		f.parks = directory
+	}
+}
+
+// WithCircuitBreaker opens the circuit after threshold consecutive failed
+// requests, failing fast with ErrUnavailable until cooldown has passed. A
+// threshold of zero or less disables the breaker.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(f *fetcher) {
+		f.breaker = newCircuitBreaker(threshold, cooldown)
+	}
 }
 
 type Client interface {
-	GetAlert(stateCode string) (*AlertDetails, error)
+	GetAlert(ctx context.Context, stateCode string) (*AlertDetails, error)
+	GetAlerts(ctx context.Context, stateCode string, opts *AlertOptions) ([]AlertDetails, error)
+	GetParkAlerts(ctx context.Context, park string, opts *AlertOptions) ([]AlertDetails, error)
 	SetTransport(http.RoundTripper)
 }
 
@@ -51,24 +137,40 @@ type npsAlert struct {
 	LastIndexedDate string `json:"lastIndexedDate,omitempty"`
 }
 
-type parkDetails struct {
-	UnitName        string   `json:"unitName,omitempty"`
-	UnitCode        string   `json:"unitCode,omitempty"`
-	UnitDesignation string   `json:"unitDesignation,omitempty"`
-	State           []string `json:"state,omitempty"`
-	EstDate         string   `json:"estDate,omitempty"`
+// AlertOptions controls how alerts are fetched. A nil *AlertOptions uses the
+// defaults.
+type AlertOptions struct {
+	// PageSize is the number of alerts requested from NPS per page.
+	// Defaults to defaultPageSize.
+	PageSize int
+	// MaxResults caps the number of alerts returned after sorting. Zero means
+	// every alert is returned.
+	MaxResults int
+	// Categories limits results to alerts in any of the given categories,
+	// compared case-insensitively. Empty means every category.
+	Categories []string
 }
 
 type AlertDetails struct {
+	// ID is the NPS alert ID, which stays the same when an alert is edited.
+	ID              string
+	ParkCode        string
 	FullStateName   string
 	FullParkName    string
+	Category        string
 	RecentAlertDate string
-	AlertHeader     string
-	AlertMessage    string
-	URL             string
+	// RecentAlertTime is RecentAlertDate parsed, or the zero time when NPS
+	// sent a date that could not be parsed.
+	RecentAlertTime time.Time
+	// StateCode is the state the alert was looked up for, or the park's
+	// first state for park lookups.
+	StateCode    string
+	AlertHeader  string
+	AlertMessage string
+	URL          string
 }
 
-func NewClient(apiKey string) (Client, error) {
+func NewClient(apiKey string, opts ...Option) (Client, error) {
 
 	if apiKey == "" {
 		return nil, fmt.Errorf("apiKey cannot be empty")
@@ -78,43 +180,280 @@ func NewClient(apiKey string) (Client, error) {
 		Timeout: time.Duration(1) * time.Second,
 	}
 
-	var stateCodes map[string]string
-	err := json.Unmarshal(stateCodesContent, &stateCodes)
+	f := &fetcher{
+		apiKey:     apiKey,
+		httpClient: c,
+		retry:      defaultRetryPolicy,
+		breaker:    newCircuitBreaker(defaultBreakerThreshold, defaultBreakerCooldown),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if f.parks == nil {
+		parks, err := NewDirectory(apiKey, "")
+		if err != nil {
+			return nil, err
+		}
+		f.parks = parks
+	}
+
+	return f, nil
+}
+
+// GetAlert returns the most recent alert for the given state. It returns an
+// error when the state has no alerts.
+func (f *fetcher) GetAlert(ctx context.Context, stateCode string) (*AlertDetails, error) {
+
+	alerts, err := f.GetAlerts(ctx, stateCode, &AlertOptions{MaxResults: 1})
+
 	if err != nil {
 		return nil, err
 	}
 
-	var parksDetails *[]parkDetails = &[]parkDetails{}
-	err = json.Unmarshal(parksDetailsContent, parksDetails)
+	if len(alerts) == 0 {
+		return nil, errorf(ErrNoAlerts, "no alerts found for state code %s", stateCode)
+	}
+
+	return &alerts[0], nil
+}
+
+// GetAlerts returns every alert for the given state, newest first. Several
+// states can be asked for at once as a comma separated list, like "CA,NV",
+// in which case each alert is attributed to the first requested state its
+// park is in. A state with no alerts yields an empty slice and a nil error.
+func (f *fetcher) GetAlerts(ctx context.Context, stateCode string, opts *AlertOptions) ([]AlertDetails, error) {
+
+	codes, invalid := splitStateCodes(stateCode)
+
+	if invalid != "" {
+		return nil, errorf(ErrInvalidState, "state code %s is not a valid state code", invalid)
+	}
+
+	q := url.Values{}
+	q.Add("stateCode", strings.Join(codes, ","))
+
+	npsAlerts, err := f.queryAlerts(ctx, q, opts)
+
 	if err != nil {
 		return nil, err
 	}
 
-	return &fetcher{
-		apiKey:     apiKey,
-		httpClient: c,
-		stateCodes: stateCodes,
-		parks:      parksDetails,
-	}, nil
+	alerts := make([]AlertDetails, 0, len(npsAlerts))
+
+	for _, a := range npsAlerts {
+		fullParkName := f.parkCodeToFullParkName(a.ParkCode)
+		code := f.alertStateCode(a.ParkCode, codes)
+		fullStateName, _ := f.stateCodeToState(code)
+
+		alerts = append(alerts, AlertDetails{
+			ID:              a.ID,
+			ParkCode:        a.ParkCode,
+			FullStateName:   fullStateName,
+			FullParkName:    fullParkName,
+			Category:        a.Category,
+			RecentAlertDate: a.LastIndexedDate,
+			RecentAlertTime: parseIndexedDate(a.LastIndexedDate),
+			StateCode:       code,
+			AlertHeader:     a.Title,
+			AlertMessage:    a.Description,
+			URL:             fmt.Sprintf(alertsUrl, code),
+		})
+	}
+
+	return alerts, nil
 }
 
-func (f *fetcher) GetAlert(stateCode string) (*AlertDetails, error) {
+// alertStateCode picks which of the requested states an alert for parkCode
+// belongs to: the first one the park is in, or the first requested state for
+// parks missing from the directory.
+func (f *fetcher) alertStateCode(parkCode string, requested []string) string {
+	if len(requested) > 1 {
+		if p, ok := f.parks.Park(parkCode); ok {
+			for _, code := range requested {
+				for _, state := range p.States {
+					if strings.EqualFold(state, code) {
+						return code
+					}
+				}
+			}
+		}
+	}
+	return requested[0]
+}
 
-	fullStateName, err := f.stateCodeToState(strings.ToUpper(stateCode))
+// GetParkAlerts returns every alert for a single park, newest first. The park
+// may be given as its NPS park code ("yose") or its name ("Yosemite"), and
+// small typos in the name are tolerated. A park with no alerts yields an empty
+// slice and a nil error. A query that does not resolve returns an
+// *UnknownParkError with the closest parks.
+func (f *fetcher) GetParkAlerts(ctx context.Context, park string, opts *AlertOptions) ([]AlertDetails, error) {
 
-	if err != nil {
-		return nil, fmt.Errorf("state code %s is not a valid state code", stateCode)
+	details, suggestions, ok := f.parks.Resolve(park)
+
+	if !ok {
+		return nil, &UnknownParkError{Query: park, Suggestions: suggestions}
 	}
 
-	req, _ := http.NewRequest("GET", baseURL, nil)
+	stateCode, fullStateName := "", ""
+	if len(details.States) > 0 {
+		stateCode = details.States[0]
+		fullStateName, _ = f.stateCodeToState(stateCode)
+	}
 
 	q := url.Values{}
-	q.Add("stateCode", stateCode)
+	q.Add("parkCode", details.Code)
+
+	npsAlerts, err := f.queryAlerts(ctx, q, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]AlertDetails, 0, len(npsAlerts))
+
+	for _, a := range npsAlerts {
+		alerts = append(alerts, AlertDetails{
+			ID:              a.ID,
+			ParkCode:        details.Code,
+			FullStateName:   fullStateName,
+			FullParkName:    details.Name,
+			Category:        a.Category,
+			RecentAlertDate: a.LastIndexedDate,
+			RecentAlertTime: parseIndexedDate(a.LastIndexedDate),
+			StateCode:       stateCode,
+			AlertHeader:     a.Title,
+			AlertMessage:    a.Description,
+			URL:             fmt.Sprintf(parkAlertsUrl, details.Code),
+		})
+	}
+
+	return alerts, nil
+}
+
+// queryAlerts fetches every alert matching q and applies opts, returning the
+// alerts newest first.
+func (f *fetcher) queryAlerts(ctx context.Context, q url.Values, opts *AlertOptions) ([]npsAlert, error) {
+
+	if opts == nil {
+		opts = &AlertOptions{}
+	}
+
+	npsAlerts, err := f.fetchAlerts(ctx, q, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(npsAlerts, func(i, j int) bool {
+		return newerAlert(npsAlerts[i], npsAlerts[j])
+	})
+
+	if opts.MaxResults > 0 && len(npsAlerts) > opts.MaxResults {
+		npsAlerts = npsAlerts[:opts.MaxResults]
+	}
+
+	return npsAlerts, nil
+}
+
+// newerAlert reports whether a was indexed after b. Alerts with dates that
+// cannot be parsed sort after every parsed date.
+func newerAlert(a, b npsAlert) bool {
+	ta, tb := parseIndexedDate(a.LastIndexedDate), parseIndexedDate(b.LastIndexedDate)
+	switch {
+	case ta.IsZero() && tb.IsZero():
+		return a.LastIndexedDate > b.LastIndexedDate
+	case ta.IsZero() || tb.IsZero():
+		return tb.IsZero()
+	}
+	return ta.After(tb)
+}
+
+// fetchAlerts walks the NPS limit/start pagination for the given query,
+// keeping the alerts in opts.Categories, until the reported total has been
+// fetched or opts.MaxResults alerts have been kept. It stops after
+// maxAlertPages pages, and fails when a page brings no alert it has not
+// already seen, rather than ask for it again forever.
+func (f *fetcher) fetchAlerts(ctx context.Context, q url.Values, opts *AlertOptions) ([]npsAlert, error) {
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	if opts.MaxResults > 0 {
+		// stopping early must still keep the newest alerts
+		q.Set("sort", "-lastIndexedDate")
+	}
+
+	alerts := []npsAlert{}
+	seen := map[string]bool{}
+	fetched := 0
+
+	for pages := 0; pages < maxAlertPages; pages++ {
+		q.Set("limit", strconv.Itoa(pageSize))
+		q.Set("start", strconv.Itoa(fetched))
+
+		page, err := f.fetchAlertPage(ctx, q)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fresh := 0
+		for _, a := range page.Data {
+			if a.ID != "" {
+				if seen[a.ID] {
+					continue
+				}
+				seen[a.ID] = true
+			}
+			fresh++
+			if len(opts.Categories) == 0 || hasCategory(opts.Categories, a.Category) {
+				alerts = append(alerts, a)
+			}
+		}
+		fetched += len(page.Data)
+
+		if len(page.Data) > 0 && fresh == 0 {
+			return nil, &DecodeError{Err: fmt.Errorf("page at start %d repeats alerts already fetched", fetched-len(page.Data))}
+		}
+
+		total, err := strconv.Atoi(page.Total)
+
+		if err != nil {
+			// without a usable total, a short page is the only end marker
+			total = fetched
+			if len(page.Data) == pageSize {
+				total++
+			}
+		}
+
+		if len(page.Data) == 0 || fetched >= total {
+			break
+		}
+		if opts.MaxResults > 0 && len(alerts) >= opts.MaxResults {
+			break
+		}
+	}
+
+	return alerts, nil
+}
+
+func (f *fetcher) fetchAlertPage(ctx context.Context, q url.Values) (*alertResponse, error) {
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
 	req.URL.RawQuery = q.Encode()
 
 	req.Header.Add("x-api-key", f.apiKey)
 
-	res, err := f.httpClient.Do(req)
+	res, err := f.do(req)
 
 	if err != nil {
 		return nil, err
@@ -122,44 +461,53 @@ func (f *fetcher) GetAlert(stateCode string) (*AlertDetails, error) {
 
 	defer res.Body.Close()
 
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+
 	alertResponse := &alertResponse{}
 
 	err = json.NewDecoder(res.Body).Decode(alertResponse)
 
 	if err != nil {
-		return nil, err
+		return nil, &DecodeError{Err: err}
 	}
 
-	fullParkName, err := f.parkCodeToFullParkName(alertResponse.Data[0].ParkCode)
+	return alertResponse, nil
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("cannot find details for park code %s", alertResponse.Data[0].ParkCode)
+// stateNames maps each state code to its full name. It is the embedded state
+// code list, parsed once for every lookup by code or name.
+var stateNames = func() map[string]string {
+	names := map[string]string{}
+	if err := json.Unmarshal(stateCodesContent, &names); err != nil {
+		panic(fmt.Sprintf("invalid embedded state codes: %s", err))
 	}
-
-	return &AlertDetails{
-		FullStateName:   fullStateName,
-		FullParkName:    fullParkName,
-		RecentAlertDate: alertResponse.Data[0].LastIndexedDate,
-		AlertHeader:     alertResponse.Data[0].Title,
-		AlertMessage:    alertResponse.Data[0].Description,
-		URL:             fmt.Sprintf(alertsUrl, stateCode),
-	}, nil
+	return names
+}()
+
+// StateName returns the full name of the state with the given code, ignoring
+// case. It returns false when the code is not a known state code.
+func StateName(stateCode string) (string, bool) {
+	name, ok := stateNames[strings.ToUpper(strings.TrimSpace(stateCode))]
+	return name, ok
 }
 
 func (f *fetcher) stateCodeToState(stateCode string) (string, error) {
-	if stateName, ok := f.stateCodes[stateCode]; ok {
+	if stateName, ok := stateNames[stateCode]; ok {
 		return stateName, nil
 	}
 	return "", fmt.Errorf("cannot find state code %s in list", stateCode)
 }
 
-func (f *fetcher) parkCodeToFullParkName(parkCode string) (string, error) {
-	for _, v := range *f.parks {
-		if v.UnitCode == parkCode {
-			return v.UnitName, nil
-		}
+// parkCodeToFullParkName returns the name of the park with the given code,
+// or the upper-cased code for parks missing from the directory so one new
+// unit cannot fail a whole state's alerts.
+func (f *fetcher) parkCodeToFullParkName(parkCode string) string {
+	if p, ok := f.parks.Park(parkCode); ok {
+		return p.Name
 	}
-	return "", fmt.Errorf("cannot find park code %s in list", parkCode)
+	return strings.ToUpper(parkCode)
 }
 
 func (f *fetcher) SetTransport(transport http.RoundTripper) {
@@ -1,11 +1,15 @@
 package nps
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -28,6 +32,41 @@ func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return response, nil
 }
 
+// pagedTransport serves one page of alerts per request, chosen by the start
+// query parameter, and reports the combined length as the total.
+type pagedTransport struct {
+	pages    [][]npsAlert
+	requests []*http.Request
+}
+
+func (m *pagedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.requests = append(m.requests, req)
+
+	start, _ := strconv.Atoi(req.URL.Query().Get("start"))
+
+	total := 0
+	page := []npsAlert{}
+	for _, p := range m.pages {
+		if total == start {
+			page = p
+		}
+		total += len(p)
+	}
+
+	body, _ := json.Marshal(alertResponse{
+		Total: strconv.Itoa(total),
+		Start: strconv.Itoa(start),
+		Data:  page,
+	})
+
+	return &http.Response{
+		Header:     make(http.Header),
+		Request:    req,
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+	}, nil
+}
+
 func TestNewClient(t *testing.T) {
 	assert := assert.New(t)
 
@@ -50,7 +89,7 @@ func TestGetAlertInvalidStateCode(t *testing.T) {
 	c, _ := NewClient("TEST_KEY")
 	c.SetTransport(mockTransport)
 
-	details, err := c.GetAlert("MV")
+	details, err := c.GetAlert(context.Background(), "MV")
 
 	assert.Nil(details)
 	assert.EqualError(err, "state code MV is not a valid state code")
@@ -81,12 +120,17 @@ func TestGetAlertSuccess(t *testing.T) {
 		},
 	}
 
-	details, err := c.GetAlert("MT")
+	details, err := c.GetAlert(context.Background(), "MT")
 
 	assert.Equal(details, &AlertDetails{
+		ID:              "TEST_ID",
+		ParkCode:        "yell",
 		FullStateName:   "Montana",
 		FullParkName:    "Yellowstone",
+		Category:        "TEST_CATEGORY",
 		RecentAlertDate: "2022-08-02 12:34:45.6",
+		RecentAlertTime: time.Date(2022, 8, 2, 12, 34, 45, 600000000, indexedDateLocation),
+		StateCode:       "MT",
 		AlertHeader:     "TEST_TITLE",
 		AlertMessage:    "TEST_DESCRIPTION",
 		URL:             "https://www.nps.gov/planyourvisit/alerts.htm?s=MT&p=1&v=0",
@@ -119,8 +163,383 @@ func TestGetAlertInvalidParkCode(t *testing.T) {
 		},
 	}
 
-	details, err := c.GetAlert("MT")
+	details, err := c.GetAlert(context.Background(), "MT")
+
+	// parks missing from the directory fall back to their code
+	assert.Nil(err)
+	assert.Equal("INVALID_CODE", details.FullParkName)
+	assert.Equal("TEST_TITLE", details.AlertHeader)
+}
+
+func TestGetAlertNoAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTransport := &mockTransport{}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(mockTransport)
+
+	mockTransport.responseBody = alertResponse{
+		Total: "0",
+		Limit: "50",
+		Start: "0",
+		Data:  []npsAlert{},
+	}
+
+	details, err := c.GetAlert(context.Background(), "MT")
 
 	assert.Nil(details)
-	assert.EqualError(err, "cannot find details for park code INVALID_CODE")
+	assert.EqualError(err, "no alerts found for state code MT")
+}
+
+func TestGetAlertsNoAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTransport := &mockTransport{}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(mockTransport)
+
+	mockTransport.responseBody = alertResponse{
+		Total: "0",
+		Limit: "50",
+		Start: "0",
+	}
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", nil)
+
+	assert.Empty(alerts)
+	assert.Nil(err)
+}
+
+func TestGetAlertsPaginatesAndSorts(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yell", Title: "OLDEST", LastIndexedDate: "2022-08-01 12:00:00.0"},
+				{ID: "2", ParkCode: "glac", Title: "NEWEST", LastIndexedDate: "2022-08-03 12:00:00.0"},
+			},
+			{
+				{ID: "3", ParkCode: "yell", Title: "MIDDLE", LastIndexedDate: "2022-08-02 12:00:00.0"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{PageSize: 2})
+
+	assert.Nil(err)
+	assert.Len(transport.requests, 2)
+	assert.Equal("0", transport.requests[0].URL.Query().Get("start"))
+	assert.Equal("2", transport.requests[1].URL.Query().Get("start"))
+	assert.Equal("2", transport.requests[1].URL.Query().Get("limit"))
+
+	assert.Len(alerts, 3)
+	assert.Equal("NEWEST", alerts[0].AlertHeader)
+	assert.Equal("Glacier", alerts[0].FullParkName)
+	assert.Equal("MIDDLE", alerts[1].AlertHeader)
+	assert.Equal("OLDEST", alerts[2].AlertHeader)
+}
+
+func TestGetAlertsMaxResults(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yell", Title: "OLDEST", LastIndexedDate: "2022-08-01 12:00:00.0"},
+				{ID: "2", ParkCode: "yell", Title: "NEWEST", LastIndexedDate: "2022-08-03 12:00:00.0"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{MaxResults: 1})
+
+	assert.Nil(err)
+	assert.Len(alerts, 1)
+	assert.Equal("NEWEST", alerts[0].AlertHeader)
+}
+
+func TestGetAlertsStopsAtMaxResults(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yell", Title: "NEWEST", LastIndexedDate: "2022-08-03 12:00:00.0"},
+				{ID: "2", ParkCode: "yell", Title: "MIDDLE", LastIndexedDate: "2022-08-02 12:00:00.0"},
+			},
+			{
+				{ID: "3", ParkCode: "yell", Title: "OLDEST", LastIndexedDate: "2022-08-01 12:00:00.0"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{PageSize: 2, MaxResults: 2})
+
+	assert.Nil(err)
+	assert.Len(transport.requests, 1)
+	assert.Equal("-lastIndexedDate", transport.requests[0].URL.Query().Get("sort"))
+	assert.Len(alerts, 2)
+}
+
+// endlessTransport answers every alerts request with a full page and no
+// usable total, with the same alerts every time when repeat is set.
+type endlessTransport struct {
+	repeat   bool
+	requests int
+}
+
+func (m *endlessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.requests++
+
+	start := req.URL.Query().Get("start")
+	if m.repeat {
+		start = "0"
+	}
+
+	body, _ := json.Marshal(alertResponse{
+		Total: "unknown",
+		Data: []npsAlert{
+			{ID: start + "-1", ParkCode: "yell"},
+			{ID: start + "-2", ParkCode: "yell"},
+		},
+	})
+
+	return &http.Response{
+		Header:     make(http.Header),
+		Request:    req,
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+	}, nil
+}
+
+func TestGetAlertsRepeatedPage(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &endlessTransport{repeat: true}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{PageSize: 2})
+
+	var decodeErr *DecodeError
+	assert.Nil(alerts)
+	assert.True(errors.As(err, &decodeErr))
+	assert.Equal(2, transport.requests)
+}
+
+func TestGetAlertsMaxPages(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &endlessTransport{}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{PageSize: 2})
+
+	assert.Nil(err)
+	assert.Equal(maxAlertPages, transport.requests)
+	assert.Len(alerts, 2*maxAlertPages)
+}
+
+func TestGetParkAlertsByCode(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yose", Title: "TEST_TITLE", Description: "TEST_DESCRIPTION", LastIndexedDate: "2022-08-02 12:34:45.6"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetParkAlerts(context.Background(), "YOSE", nil)
+
+	assert.Nil(err)
+	assert.Equal("yose", transport.requests[0].URL.Query().Get("parkCode"))
+	assert.Equal([]AlertDetails{
+		{
+			ID:              "1",
+			ParkCode:        "yose",
+			FullStateName:   "California",
+			FullParkName:    "Yosemite",
+			RecentAlertDate: "2022-08-02 12:34:45.6",
+			RecentAlertTime: time.Date(2022, 8, 2, 12, 34, 45, 600000000, indexedDateLocation),
+			StateCode:       "CA",
+			AlertHeader:     "TEST_TITLE",
+			AlertMessage:    "TEST_DESCRIPTION",
+			URL:             "https://www.nps.gov/yose/planyourvisit/conditions.htm",
+		},
+	}, alerts)
+}
+
+func TestGetParkAlertsByName(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetParkAlerts(context.Background(), " yosemite ", nil)
+
+	assert.Nil(err)
+	assert.Empty(alerts)
+	assert.Equal("yose", transport.requests[0].URL.Query().Get("parkCode"))
+}
+
+func TestGetParkAlertsUnknownPark(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetParkAlerts(context.Background(), "not a park", nil)
+
+	assert.Nil(alerts)
+	assert.ErrorIs(err, ErrUnknownPark)
+	assert.Empty(transport.requests)
+}
+
+func TestGetParkAlertsTypo(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	_, err := c.GetParkAlerts(context.Background(), "yellowstne", nil)
+
+	assert.Nil(err)
+	assert.Equal("yell", transport.requests[0].URL.Query().Get("parkCode"))
+}
+
+func TestGetParkAlertsSuggestions(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(&pagedTransport{})
+
+	_, err := c.GetParkAlerts(context.Background(), "grand", nil)
+
+	var unknownParkErr *UnknownParkError
+	assert.ErrorAs(err, &unknownParkErr)
+	assert.Equal("grand", unknownParkErr.Query)
+	assert.NotEmpty(unknownParkErr.Suggestions)
+	assert.EqualError(err, `cannot find a park matching "grand"`)
+}
+
+func TestGetAlertsCategoryFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yell", Title: "ROAD CLOSED", Category: "Park Closure", LastIndexedDate: "2022-08-01 12:00:00.0"},
+				{ID: "2", ParkCode: "yell", Title: "BEARS", Category: "Danger", LastIndexedDate: "2022-08-03 12:00:00.0"},
+				{ID: "3", ParkCode: "yell", Title: "VISITOR CENTER", Category: "Information", LastIndexedDate: "2022-08-02 12:00:00.0"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", &AlertOptions{Categories: []string{"park closure"}})
+
+	assert.Nil(err)
+	assert.Len(alerts, 1)
+	assert.Equal("ROAD CLOSED", alerts[0].AlertHeader)
+	assert.Equal(CategoryClosure, alerts[0].Category)
+
+	alerts, err = c.GetAlerts(context.Background(), "MT", &AlertOptions{Categories: []string{CategoryDanger, CategoryInformation}})
+
+	assert.Nil(err)
+	assert.Len(alerts, 2)
+	assert.Equal("BEARS", alerts[0].AlertHeader)
+	assert.Equal("VISITOR CENTER", alerts[1].AlertHeader)
+}
+
+func TestParseCategory(t *testing.T) {
+	assert := assert.New(t)
+
+	category, ok := ParseCategory("Closures")
+	assert.True(ok)
+	assert.Equal(CategoryClosure, category)
+
+	category, ok = ParseCategory("danger")
+	assert.True(ok)
+	assert.Equal(CategoryDanger, category)
+
+	category, ok = ParseCategory("info")
+	assert.True(ok)
+	assert.Equal(CategoryInformation, category)
+
+	_, ok = ParseCategory("yosemite")
+	assert.False(ok)
+}
+
+func TestGetAlertsMultipleStates(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yell", Title: "YELLOWSTONE", LastIndexedDate: "2022-08-02 12:00:00.0"},
+				{ID: "2", ParkCode: "yose", Title: "YOSEMITE", LastIndexedDate: "2022-08-01 12:00:00.0"},
+				{ID: "3", ParkCode: "zzzz", Title: "UNKNOWN", LastIndexedDate: "2022-07-31 12:00:00.0"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "ca, mt", nil)
+
+	assert.Nil(err)
+	assert.Equal("CA,MT", transport.requests[0].URL.Query().Get("stateCode"))
+	assert.Len(alerts, 3)
+
+	assert.Equal("MT", alerts[0].StateCode)
+	assert.Equal("Montana", alerts[0].FullStateName)
+	assert.Equal("https://www.nps.gov/planyourvisit/alerts.htm?s=MT&p=1&v=0", alerts[0].URL)
+
+	assert.Equal("CA", alerts[1].StateCode)
+	assert.Equal("California", alerts[1].FullStateName)
+
+	// parks missing from the directory go to the first state asked for
+	assert.Equal("CA", alerts[2].StateCode)
+}
+
+func TestGetAlertsMultipleStatesInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(&mockTransport{})
+
+	alerts, err := c.GetAlerts(context.Background(), "CA,ZZ", nil)
+
+	assert.Nil(alerts)
+	assert.ErrorIs(err, ErrInvalidState)
+	assert.EqualError(err, "state code ZZ is not a valid state code")
 }
@@ -0,0 +1,334 @@
+package nps
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const maxSuggestions = 3
+
+// diacritics folds the accented characters and marks found in park names,
+// such as Haleakalā and Hawaiʻi, so they match what people type on a phone.
+var diacritics = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ā", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "ō", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u",
+	"ñ", "n", "ç", "c",
+	"ʻ", "", "’", "", "'", "", "&", " and ",
+)
+
+// Registry indexes parks by code, state and normalized name, and resolves free text to a park with typo tolerance. A Registry is
+// immutable once built.
+type Registry struct {
+	parks   []Park
+	byCode  map[string]Park
+	byState map[string][]Park
+	byName  map[string][]Park
+
+	// names pairs every normalized name with its park for fuzzy matching
+	names []indexedName
+
+	// designationSuffixes are normalized designations, longest first, that
+	// may be dropped from the end of a query
+	designationSuffixes []string
+}
+
+type indexedName struct {
+	name string
+	park Park
+}
+
+// NewRegistry builds a Registry over parks, which are kept sorted by code.
+func NewRegistry(parks []Park) *Registry {
+
+	sorted := make([]Park, len(parks))
+	copy(sorted, parks)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Code < sorted[j].Code })
+
+	r := &Registry{
+		parks:   sorted,
+		byCode:  make(map[string]Park, len(parks)),
+		byState: map[string][]Park{},
+		byName:  map[string][]Park{},
+	}
+
+	suffixes := map[string]bool{}
+	for abbreviation, designation := range designations {
+		suffixes[normalize(abbreviation)] = true
+		suffixes[normalize(designation)] = true
+	}
+
+	for _, p := range sorted {
+		r.byCode[strings.ToLower(p.Code)] = p
+
+		for _, state := range p.States {
+			state = strings.ToUpper(state)
+			r.byState[state] = append(r.byState[state], p)
+		}
+
+		if d := normalize(p.Designation); d != "" {
+			suffixes[d] = true
+		}
+
+		for _, name := range uniqueNames(p) {
+			r.byName[name] = append(r.byName[name], p)
+			r.names = append(r.names, indexedName{name: name, park: p})
+		}
+	}
+
+	for suffix := range suffixes {
+		if suffix != "" {
+			r.designationSuffixes = append(r.designationSuffixes, suffix)
+		}
+	}
+	sort.Slice(r.designationSuffixes, func(i, j int) bool {
+		return len(r.designationSuffixes[i]) > len(r.designationSuffixes[j])
+	})
+
+	return r
+}
+
+// Parks returns every park, sorted by code.
+func (r *Registry) Parks() []Park {
+	parks := make([]Park, len(r.parks))
+	copy(parks, r.parks)
+	return parks
+}
+
+// Park returns the park with the given code, ignoring case.
+func (r *Registry) Park(code string) (Park, bool) {
+	p, ok := r.byCode[strings.ToLower(strings.TrimSpace(code))]
+	return p, ok
+}
+
+// ParksInState returns the parks in the given state, sorted by code.
+func (r *Registry) ParksInState(stateCode string) []Park {
+	return r.byState[strings.ToUpper(strings.TrimSpace(stateCode))]
+}
+
+// Resolve finds the park a user most likely meant by query. Codes and exact
+// names, with or without a designation such as "national park", win. Failing
+// that, a unique name within a few typos, or a unique name starting with the
+// query, is used. When no single park fits, ok is false and suggestions holds
+// the closest parks for a "did you mean" reply.
+func (r *Registry) Resolve(query string) (park Park, suggestions []Park, ok bool) {
+
+	if p, ok := r.Park(query); ok {
+		return p, nil, true
+	}
+
+	q := normalize(query)
+	if q == "" {
+		return Park{}, nil, false
+	}
+
+	candidates := []string{q}
+	if stripped := r.stripDesignation(q); stripped != q && stripped != "" {
+		candidates = append(candidates, stripped)
+	}
+
+	for _, c := range candidates {
+		if parks := r.byName[c]; len(parks) == 1 {
+			return parks[0], nil, true
+		} else if len(parks) > 1 {
+			return Park{}, limit(parks), false
+		}
+	}
+
+	// typos: the closest names within the tolerance for the query length
+	best, bestDistance := []Park{}, -1
+	for _, c := range candidates {
+		tolerance := typoTolerance(c)
+		for _, n := range r.names {
+			d := editDistance(c, n.name)
+			if d > tolerance {
+				continue
+			}
+			if bestDistance == -1 || d < bestDistance {
+				best, bestDistance = []Park{n.park}, d
+			} else if d == bestDistance {
+				best = appendPark(best, n.park)
+			}
+		}
+	}
+	if len(best) == 1 {
+		return best[0], nil, true
+	}
+	if len(best) > 1 {
+		return Park{}, limit(best), false
+	}
+
+	// partial names: names starting with the query at a word boundary
+	prefixed := []Park{}
+	for _, c := range candidates {
+		if len(c) < 4 {
+			continue
+		}
+		for _, n := range r.names {
+			if strings.HasPrefix(n.name, c+" ") {
+				prefixed = appendPark(prefixed, n.park)
+			}
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], nil, true
+	}
+	if len(prefixed) > 1 {
+		return Park{}, limit(prefixed), false
+	}
+
+	return Park{}, r.suggest(q), false
+}
+
+// suggest returns the parks whose names are closest to q, for queries too
+// far from every name to resolve.
+func (r *Registry) suggest(q string) []Park {
+
+	type scored struct {
+		park     Park
+		distance int
+	}
+
+	limitDistance := 2*typoTolerance(q) + 2
+
+	best := map[string]scored{}
+	for _, n := range r.names {
+		d := editDistance(q, n.name)
+		if d > limitDistance {
+			continue
+		}
+		if s, ok := best[n.park.Code]; !ok || d < s.distance {
+			best[n.park.Code] = scored{park: n.park, distance: d}
+		}
+	}
+
+	ranked := make([]scored, 0, len(best))
+	for _, s := range best {
+		ranked = append(ranked, s)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].distance != ranked[j].distance {
+			return ranked[i].distance < ranked[j].distance
+		}
+		return ranked[i].park.Code < ranked[j].park.Code
+	})
+
+	parks := []Park{}
+	for _, s := range ranked {
+		parks = append(parks, s.park)
+	}
+	return limit(parks)
+}
+
+func (r *Registry) stripDesignation(q string) string {
+	for _, suffix := range r.designationSuffixes {
+		if strings.HasSuffix(q, " "+suffix) {
+			return strings.TrimSuffix(q, " "+suffix)
+		}
+	}
+	return q
+}
+
+// uniqueNames returns the normalized short and full names of a park.
+func uniqueNames(p Park) []string {
+	names := []string{}
+	for _, name := range []string{p.Name, p.FullName} {
+		n := normalize(name)
+		if n != "" && (len(names) == 0 || names[0] != n) {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// normalize lower-cases s, folds accents and turns punctuation into single
+// spaces, so "Hawaiʻi Volcanoes" and "hawaii  volcanoes!" compare equal.
+func normalize(s string) string {
+	s = diacritics.Replace(strings.ToLower(s))
+
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// typoTolerance is how many edits a query of this length may be away from
+// a name and still match it.
+func typoTolerance(q string) int {
+	t := len(q) / 5
+	if t > 3 {
+		t = 3
+	}
+	return t
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and swaps of neighbouring characters
+// each count as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func appendPark(parks []Park, p Park) []Park {
+	for _, existing := range parks {
+		if existing.Code == p.Code {
+			return parks
+		}
+	}
+	return append(parks, p)
+}
+
+func limit(parks []Park) []Park {
+	if len(parks) > maxSuggestions {
+		return parks[:maxSuggestions]
+	}
+	return parks
+}
@@ -0,0 +1,165 @@
+package nps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRegistry() *Registry {
+	return NewRegistry([]Park{
+		{Code: "yell", Name: "Yellowstone", FullName: "Yellowstone National Park", Designation: "National Park", States: []string{"ID", "MT", "WY"}},
+		{Code: "grte", Name: "Grand Teton", FullName: "Grand Teton National Park", Designation: "National Park", States: []string{"WY"}},
+		{Code: "grca", Name: "Grand Canyon", FullName: "Grand Canyon National Park", Designation: "National Park", States: []string{"AZ"}},
+		{Code: "fobu", Name: "Fossil Butte", FullName: "Fossil Butte National Monument", Designation: "National Monument", States: []string{"WY"}},
+		{Code: "havo", Name: "Hawaiʻi Volcanoes", FullName: "Hawaiʻi Volcanoes National Park", Designation: "National Park", States: []string{"HI"}},
+	})
+}
+
+func TestRegistryPark(t *testing.T) {
+	assert := assert.New(t)
+
+	p, ok := testRegistry().Park(" GRTE ")
+
+	assert.True(ok)
+	assert.Equal("Grand Teton", p.Name)
+}
+
+func TestRegistryParksInState(t *testing.T) {
+	assert := assert.New(t)
+
+	parks := testRegistry().ParksInState("wy")
+
+	assert.Len(parks, 3)
+	assert.Equal("fobu", parks[0].Code)
+	assert.Equal("grte", parks[1].Code)
+	assert.Equal("yell", parks[2].Code)
+}
+
+func TestRegistryResolveName(t *testing.T) {
+	assert := assert.New(t)
+
+	p, suggestions, ok := testRegistry().Resolve("grand teton")
+
+	assert.True(ok)
+	assert.Equal("grte", p.Code)
+	assert.Empty(suggestions)
+}
+
+func TestRegistryResolveDesignation(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testRegistry()
+
+	p, _, ok := r.Resolve("Grand Teton National Park")
+	assert.True(ok)
+	assert.Equal("grte", p.Code)
+
+	p, _, ok = r.Resolve("grand teton np")
+	assert.True(ok)
+	assert.Equal("grte", p.Code)
+}
+
+func TestRegistryResolveDiacritics(t *testing.T) {
+	assert := assert.New(t)
+
+	p, _, ok := testRegistry().Resolve("hawaii volcanoes!")
+
+	assert.True(ok)
+	assert.Equal("havo", p.Code)
+}
+
+func TestRegistryResolveTypo(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testRegistry()
+
+	p, _, ok := r.Resolve("yellowstne")
+	assert.True(ok)
+	assert.Equal("yell", p.Code)
+
+	p, _, ok = r.Resolve("yelowstone national park")
+	assert.True(ok)
+	assert.Equal("yell", p.Code)
+
+	p, _, ok = r.Resolve("grand tteon")
+	assert.True(ok)
+	assert.Equal("grte", p.Code)
+}
+
+func TestRegistryResolvePrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	p, _, ok := testRegistry().Resolve("fossil")
+
+	assert.True(ok)
+	assert.Equal("fobu", p.Code)
+}
+
+func TestRegistryResolveAmbiguous(t *testing.T) {
+	assert := assert.New(t)
+
+	_, suggestions, ok := testRegistry().Resolve("grand")
+
+	assert.False(ok)
+	assert.Len(suggestions, 2)
+	assert.Equal("grca", suggestions[0].Code)
+	assert.Equal("grte", suggestions[1].Code)
+}
+
+func TestRegistryResolveSuggestions(t *testing.T) {
+	assert := assert.New(t)
+
+	_, suggestions, ok := testRegistry().Resolve("yellowstoneee park")
+
+	assert.False(ok)
+	assert.Len(suggestions, 1)
+	assert.Equal("yell", suggestions[0].Code)
+}
+
+func TestRegistryResolveUnknown(t *testing.T) {
+	assert := assert.New(t)
+
+	_, suggestions, ok := testRegistry().Resolve("xyzzy")
+
+	assert.False(ok)
+	assert.Empty(suggestions)
+}
+
+func TestRegistryResolveShortQueriesAreExact(t *testing.T) {
+	assert := assert.New(t)
+
+	// four letters is a park code, so no typos are tolerated
+	_, _, ok := testRegistry().Resolve("yelk")
+
+	assert.False(ok)
+}
+
+func TestRegistryEmbeddedCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	d, _ := NewDirectory("TEST_KEY", "")
+	r := d.Registry()
+
+	p, _, ok := r.Resolve("yellowstne")
+	assert.True(ok)
+	assert.Equal("yell", p.Code)
+
+	p, _, ok = r.Resolve("grand teton")
+	assert.True(ok)
+	assert.Equal("grte", p.Code)
+
+	p, _, ok = r.Resolve("glaicer")
+	assert.True(ok)
+	assert.Equal("glac", p.Code)
+}
+
+func TestEditDistance(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, editDistance("zion", "zion"))
+	assert.Equal(1, editDistance("yellowstne", "yellowstone"))
+	assert.Equal(1, editDistance("glaicer", "glacier"))
+	assert.Equal(3, editDistance("", "abc"))
+	assert.Equal(3, editDistance("kitten", "sitting"))
+}
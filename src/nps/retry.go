@@ -0,0 +1,136 @@
+package nps
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"go.uber.org/zap"
+)
+
+var defaultRetryPolicy = retryPolicy{
+	maxRetries: 2,
+	baseDelay:  250 * time.Millisecond,
+	maxDelay:   2 * time.Second,
+}
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// backoff returns the delay before the given retry attempt, starting at zero.
+// The delay doubles each attempt up to maxDelay and is jittered into the upper
+// half of that range so concurrent callers spread out.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay
+	for i := 0; i < attempt && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	if d > p.maxDelay {
+		d = p.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. It returns zero when the header is missing or invalid.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// do sends req, retrying transport errors, 429s and 5xx responses according
+// to the retry policy. Every outcome is reported to the circuit breaker, and
+// requests are refused with ErrUnavailable while it is open. Other non-200
+// responses are returned to the caller as is. A rate limit that outlasts the
+// retries is reported as a *StatusError matching ErrRateLimited.
+func (f *fetcher) do(req *http.Request) (*http.Response, error) {
+
+	if !f.breaker.allow() {
+		return nil, fmt.Errorf("circuit breaker open: %w", ErrUnavailable)
+	}
+
+	var lastErr error
+
+	for attempt := 0; ; attempt++ {
+		res, err := f.httpClient.Do(req)
+
+		var retryAfter time.Duration
+
+		if err != nil {
+			if req.Context().Err() != nil {
+				// the caller gave up, which says nothing about the health of NPS
+				f.breaker.release()
+				return nil, req.Context().Err()
+			}
+			lastErr = err
+		} else if retryableStatus(res.StatusCode) {
+			retryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+			res.Body.Close()
+			lastErr = &StatusError{StatusCode: res.StatusCode}
+		} else {
+			f.breaker.success()
+			return res, nil
+		}
+
+		if attempt >= f.retry.maxRetries || retryAfter > f.retry.maxDelay {
+			break
+		}
+
+		delay := f.retry.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		logging.FromContext(req.Context()).Warn("retrying nps request",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(lastErr))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			f.breaker.release()
+			return nil, req.Context().Err()
+		}
+	}
+
+	f.breaker.failure()
+
+	// status errors already match ErrRateLimited or ErrUnavailable
+	var statusErr *StatusError
+	if errors.As(lastErr, &statusErr) {
+		return nil, statusErr
+	}
+
+	return nil, fmt.Errorf("%s: %w", lastErr, ErrUnavailable)
+}
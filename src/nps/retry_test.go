@@ -0,0 +1,196 @@
+package nps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockResponse struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+// sequenceTransport answers each request with the next canned response,
+// repeating the last one once the sequence runs out.
+type sequenceTransport struct {
+	responses []mockResponse
+	calls     int
+}
+
+func (m *sequenceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := m.responses[len(m.responses)-1]
+	if m.calls < len(m.responses) {
+		r = m.responses[m.calls]
+	}
+	m.calls++
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	body, _ := json.Marshal(alertResponse{Total: "0"})
+
+	response := &http.Response{
+		Header:     make(http.Header),
+		Request:    req,
+		StatusCode: r.status,
+		Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+	}
+	if r.retryAfter != "" {
+		response.Header.Set("Retry-After", r.retryAfter)
+	}
+	return response, nil
+}
+
+func TestRetryRecovers(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &sequenceTransport{
+		responses: []mockResponse{
+			{status: http.StatusServiceUnavailable},
+			{status: http.StatusTooManyRequests, retryAfter: "0"},
+			{status: http.StatusOK},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY", WithRetries(2, time.Millisecond, 5*time.Millisecond))
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "CA", nil)
+
+	assert.Nil(err)
+	assert.Empty(alerts)
+	assert.Equal(3, transport.calls)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &sequenceTransport{
+		responses: []mockResponse{{err: errors.New("TEST_CONNECTION_RESET")}},
+	}
+
+	c, _ := NewClient("TEST_KEY", WithRetries(2, time.Millisecond, 5*time.Millisecond))
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "CA", nil)
+
+	assert.Nil(alerts)
+	assert.ErrorIs(err, ErrUnavailable)
+	assert.Equal(3, transport.calls)
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &sequenceTransport{
+		responses: []mockResponse{{status: http.StatusTooManyRequests, retryAfter: "120"}},
+	}
+
+	c, _ := NewClient("TEST_KEY", WithRetries(2, time.Millisecond, 5*time.Millisecond))
+	c.SetTransport(transport)
+
+	_, err := c.GetAlerts(context.Background(), "CA", nil)
+
+	assert.ErrorIs(err, ErrRateLimited)
+	assert.Equal(1, transport.calls)
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &sequenceTransport{
+		responses: []mockResponse{{status: http.StatusForbidden}},
+	}
+
+	c, _ := NewClient("TEST_KEY", WithRetries(2, time.Millisecond, 5*time.Millisecond))
+	c.SetTransport(transport)
+
+	_, err := c.GetAlerts(context.Background(), "CA", nil)
+
+	assert.EqualError(err, "nps api responded with status 403")
+	assert.False(errors.Is(err, ErrUnavailable))
+	assert.Equal(1, transport.calls)
+}
+
+func TestCircuitBreakerShortCircuits(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &sequenceTransport{
+		responses: []mockResponse{{status: http.StatusBadGateway}},
+	}
+
+	c, _ := NewClient("TEST_KEY",
+		WithRetries(0, time.Millisecond, time.Millisecond),
+		WithCircuitBreaker(2, time.Minute),
+	)
+	c.SetTransport(transport)
+
+	_, _ = c.GetAlerts(context.Background(), "CA", nil)
+	_, _ = c.GetAlerts(context.Background(), "CA", nil)
+	_, err := c.GetAlerts(context.Background(), "CA", nil)
+
+	assert.EqualError(err, "circuit breaker open: nps api is unavailable")
+	assert.Equal(2, transport.calls)
+}
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	p := retryPolicy{maxRetries: 5, baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	for i := 0; i < 20; i++ {
+		d := p.backoff(0)
+		assert.GreaterOrEqual(d, 50*time.Millisecond)
+		assert.LessOrEqual(d, 100*time.Millisecond)
+
+		d = p.backoff(2)
+		assert.GreaterOrEqual(d, 200*time.Millisecond)
+		assert.LessOrEqual(d, 400*time.Millisecond)
+
+		d = p.backoff(10)
+		assert.GreaterOrEqual(d, 500*time.Millisecond)
+		assert.LessOrEqual(d, time.Second)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(3*time.Second, parseRetryAfter("3", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(time.Minute, parseRetryAfter("Tue, 02 Aug 2022 12:01:00 GMT", now))
+	assert.Equal(time.Duration(0), parseRetryAfter("Tue, 02 Aug 2022 11:00:00 GMT", now))
+}
+
+func TestRetryStopsWhenContextCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &sequenceTransport{
+		responses: []mockResponse{{err: errors.New("TEST_CONNECTION_RESET")}},
+	}
+
+	c, _ := NewClient("TEST_KEY", WithRetries(2, time.Millisecond, 5*time.Millisecond))
+	c.SetTransport(transport)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.GetAlerts(ctx, "CA", nil)
+
+	assert.ErrorIs(err, context.Canceled)
+	assert.False(errors.Is(err, ErrUnavailable))
+	assert.Equal(1, transport.calls)
+}
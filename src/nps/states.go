@@ -0,0 +1,108 @@
+package nps
+
+import (
+	"strings"
+)
+
+// stateNameAliases are other names texters use for states and territories.
+var stateNameAliases = map[string]string{
+	"washington dc":     "DC",
+	"washington d c":    "DC",
+	"us virgin islands": "VI",
+	"micronesia":        "FM",
+	"mariana islands":   "MP",
+}
+
+// stateCodesByName maps normalized state and territory names, like
+// "new mexico", to their codes.
+var stateCodesByName = func() map[string]string {
+	codes := map[string]string{}
+	for code, name := range stateNames {
+		codes[normalize(name)] = code
+	}
+	for name, code := range stateNameAliases {
+		codes[name] = code
+	}
+	return codes
+}()
+
+// maxStateNameWords is the most words in any name in stateCodesByName.
+var maxStateNameWords = func() int {
+	most := 0
+	for name := range stateCodesByName {
+		if n := len(strings.Fields(name)); n > most {
+			most = n
+		}
+	}
+	return most
+}()
+
+// stateListConnectors are words between states that are not part of a name.
+var stateListConnectors = map[string]bool{
+	"and": true,
+	"&":   true,
+}
+
+// ResolveState returns the code of the state or territory matching query,
+// which may be a code like "nm" or a name like "New Mexico".
+func ResolveState(query string) (string, bool) {
+	if _, ok := StateName(query); ok {
+		return strings.ToUpper(strings.TrimSpace(query)), true
+	}
+	code, ok := stateCodesByName[normalize(query)]
+	return code, ok
+}
+
+// ParseStates reads a list of states from words, such as ["utah"],
+// ["new", "mexico"] or ["CA", "NV", "AZ"]. Names may span several words and
+// "and" between states is skipped. It returns false unless every word is part
+// of a state. Each code is returned once, in the order first given.
+func ParseStates(words []string) ([]string, bool) {
+
+	codes := []string{}
+	seen := map[string]bool{}
+
+	for i := 0; i < len(words); {
+		if stateListConnectors[strings.ToLower(words[i])] && i > 0 && i < len(words)-1 {
+			i++
+			continue
+		}
+
+		// prefer the longest name, so "west virginia" is not read as "west"
+		// followed by Virginia
+		matched := 0
+		code := ""
+		for n := minInt(maxStateNameWords, len(words)-i); n > 0; n-- {
+			if c, ok := ResolveState(strings.Join(words[i:i+n], " ")); ok {
+				matched, code = n, c
+				break
+			}
+		}
+		if matched == 0 {
+			return nil, false
+		}
+
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+		i += matched
+	}
+
+	return codes, len(codes) > 0
+}
+
+// splitStateCodes splits a comma separated list of state codes, such as
+// "CA,NV", into upper-case codes. It returns the first code that is not a
+// known state code as invalid.
+func splitStateCodes(stateCodes string) ([]string, string) {
+	codes := []string{}
+	for _, code := range strings.Split(stateCodes, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if _, ok := stateNames[code]; !ok {
+			return nil, code
+		}
+		codes = append(codes, code)
+	}
+	return codes, ""
+}
@@ -0,0 +1,83 @@
+package nps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveStateCode(t *testing.T) {
+	assert := assert.New(t)
+
+	code, ok := ResolveState(" nm ")
+
+	assert.True(ok)
+	assert.Equal("NM", code)
+}
+
+func TestResolveStateName(t *testing.T) {
+	assert := assert.New(t)
+
+	code, ok := ResolveState("New  MEXICO")
+	assert.True(ok)
+	assert.Equal("NM", code)
+
+	code, ok = ResolveState("district of columbia")
+	assert.True(ok)
+	assert.Equal("DC", code)
+
+	code, ok = ResolveState("Washington, D.C.")
+	assert.True(ok)
+	assert.Equal("DC", code)
+}
+
+func TestResolveStateTerritory(t *testing.T) {
+	assert := assert.New(t)
+
+	code, ok := ResolveState("puerto rico")
+	assert.True(ok)
+	assert.Equal("PR", code)
+
+	code, ok = ResolveState("US Virgin Islands")
+	assert.True(ok)
+	assert.Equal("VI", code)
+}
+
+func TestResolveStateUnknown(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ok := ResolveState("yosemite")
+	assert.False(ok)
+}
+
+func TestParseStatesNames(t *testing.T) {
+	assert := assert.New(t)
+
+	codes, ok := ParseStates([]string{"new", "mexico", "west", "virginia", "and", "Utah"})
+
+	assert.True(ok)
+	assert.Equal([]string{"NM", "WV", "UT"}, codes)
+}
+
+func TestParseStatesCodes(t *testing.T) {
+	assert := assert.New(t)
+
+	codes, ok := ParseStates([]string{"CA", "nv", "AZ", "ca"})
+
+	assert.True(ok)
+	assert.Equal([]string{"CA", "NV", "AZ"}, codes)
+}
+
+func TestParseStatesNotAllStates(t *testing.T) {
+	assert := assert.New(t)
+
+	codes, ok := ParseStates([]string{"utah", "arches"})
+	assert.False(ok)
+	assert.Nil(codes)
+
+	_, ok = ParseStates([]string{"and"})
+	assert.False(ok)
+
+	_, ok = ParseStates(nil)
+	assert.False(ok)
+}
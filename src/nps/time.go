@@ -0,0 +1,118 @@
+package nps
+
+import (
+	"strings"
+	"time"
+
+	// embed the tz database so park time zones resolve in minimal images
+	_ "time/tzdata"
+)
+
+const (
+	// indexedDateLayout is the layout of lastIndexedDate values such as
+	// "2022-06-07 17:55:48.0". The trailing fractional seconds are accepted
+	// by time.Parse without being part of the layout.
+	indexedDateLayout = "2006-01-02 15:04:05"
+
+	// indexedDateZone is the zone NPS timestamps are reported in. The API
+	// does not include an offset, so this follows the NPS headquarters.
+	indexedDateZone = "America/New_York"
+)
+
+var indexedDateLocation = loadLocation(indexedDateZone)
+
+// stateTimeZones maps each state code to the time zone covering most of its
+// parks. States spanning several zones use the one most of their land is in.
+var stateTimeZones = map[string]string{
+	"AL": "America/Chicago",
+	"AK": "America/Anchorage",
+	"AS": "Pacific/Pago_Pago",
+	"AZ": "America/Phoenix",
+	"AR": "America/Chicago",
+	"CA": "America/Los_Angeles",
+	"CO": "America/Denver",
+	"CT": "America/New_York",
+	"DE": "America/New_York",
+	"DC": "America/New_York",
+	"FM": "Pacific/Pohnpei",
+	"FL": "America/New_York",
+	"GA": "America/New_York",
+	"GU": "Pacific/Guam",
+	"HI": "Pacific/Honolulu",
+	"ID": "America/Boise",
+	"IL": "America/Chicago",
+	"IN": "America/Indiana/Indianapolis",
+	"IA": "America/Chicago",
+	"KS": "America/Chicago",
+	"KY": "America/New_York",
+	"LA": "America/Chicago",
+	"ME": "America/New_York",
+	"MH": "Pacific/Majuro",
+	"MD": "America/New_York",
+	"MA": "America/New_York",
+	"MI": "America/Detroit",
+	"MN": "America/Chicago",
+	"MS": "America/Chicago",
+	"MO": "America/Chicago",
+	"MT": "America/Denver",
+	"NE": "America/Chicago",
+	"NV": "America/Los_Angeles",
+	"NH": "America/New_York",
+	"NJ": "America/New_York",
+	"NM": "America/Denver",
+	"NY": "America/New_York",
+	"NC": "America/New_York",
+	"ND": "America/Chicago",
+	"MP": "Pacific/Saipan",
+	"OH": "America/New_York",
+	"OK": "America/Chicago",
+	"OR": "America/Los_Angeles",
+	"PW": "Pacific/Palau",
+	"PA": "America/New_York",
+	"PR": "America/Puerto_Rico",
+	"RI": "America/New_York",
+	"SC": "America/New_York",
+	"SD": "America/Chicago",
+	"TN": "America/Chicago",
+	"TX": "America/Chicago",
+	"UT": "America/Denver",
+	"VT": "America/New_York",
+	"VI": "America/St_Thomas",
+	"VA": "America/New_York",
+	"WA": "America/Los_Angeles",
+	"WV": "America/New_York",
+	"WI": "America/Chicago",
+	"WY": "America/Denver",
+}
+
+// StateLocation returns the time zone used for parks in the given state. It
+// returns false when the state code is unknown.
+func StateLocation(stateCode string) (*time.Location, bool) {
+	name, ok := stateTimeZones[strings.ToUpper(stateCode)]
+	if !ok {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseIndexedDate parses an NPS lastIndexedDate. It returns the zero time
+// when the value cannot be parsed.
+func parseIndexedDate(s string) time.Time {
+	t, err := time.ParseInLocation(indexedDateLayout, strings.TrimSpace(s), indexedDateLocation)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,69 @@
+package nps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIndexedDate(t *testing.T) {
+	assert := assert.New(t)
+
+	parsed := parseIndexedDate("2022-06-07 17:55:48.0")
+
+	assert.Equal(time.Date(2022, 6, 7, 21, 55, 48, 0, time.UTC), parsed.UTC())
+
+	assert.True(parseIndexedDate("").IsZero())
+	assert.True(parseIndexedDate("yesterday").IsZero())
+}
+
+func TestStateLocation(t *testing.T) {
+	assert := assert.New(t)
+
+	loc, ok := StateLocation("ca")
+	assert.True(ok)
+	assert.Equal("America/Los_Angeles", loc.String())
+
+	loc, ok = StateLocation("GU")
+	assert.True(ok)
+	assert.Equal("Pacific/Guam", loc.String())
+
+	_, ok = StateLocation("MV")
+	assert.False(ok)
+}
+
+func TestEveryStateHasLocation(t *testing.T) {
+	assert := assert.New(t)
+
+	for code := range stateNames {
+		_, ok := StateLocation(code)
+		assert.True(ok, code)
+	}
+}
+
+func TestGetAlertsSortsByParsedTime(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &pagedTransport{
+		pages: [][]npsAlert{
+			{
+				{ID: "1", ParkCode: "yell", Title: "UNPARSEABLE", LastIndexedDate: "unknown"},
+				{ID: "2", ParkCode: "yell", Title: "OLDER", LastIndexedDate: "2022-08-02 9:00:00.0"},
+				{ID: "3", ParkCode: "yell", Title: "NEWER", LastIndexedDate: "2022-08-02 10:00:00.0"},
+			},
+		},
+	}
+
+	c, _ := NewClient("TEST_KEY")
+	c.SetTransport(transport)
+
+	alerts, err := c.GetAlerts(context.Background(), "MT", nil)
+
+	assert.Nil(err)
+	assert.Equal("NEWER", alerts[0].AlertHeader)
+	assert.Equal("OLDER", alerts[1].AlertHeader)
+	assert.Equal("UNPARSEABLE", alerts[2].AlertHeader)
+	assert.True(alerts[2].RecentAlertTime.IsZero())
+}
@@ -0,0 +1,226 @@
+// Package poller texts subscribers new NPS alerts as they are posted.
+package poller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/WilliamDeBruin/nps_alerts/src/twilio"
+	"go.uber.org/zap"
+)
+
+// saveTimeout bounds saving the alerts a poll sent after its context was
+// cancelled, as it is when the server shuts down mid-poll.
+const saveTimeout = 5 * time.Second
+
+// FormatFunc renders the text sent to a subscriber for a new alert.
+type FormatFunc func(ctx context.Context, sub store.Subscription, alert nps.AlertDetails) (string, error)
+
+// Poller periodically checks every topic with subscribers for alerts it has
+// not seen before and texts them to the topic's subscribers.
+type Poller struct {
+	npsClient    nps.Client
+	twilioClient twilio.Client
+	store        store.Store
+	format       FormatFunc
+}
+
+// New returns a Poller that looks alerts up with npsClient, texts them with
+// twilioClient rendered by format, and keeps subscriptions and seen alerts in
+// st.
+func New(npsClient nps.Client, twilioClient twilio.Client, st store.Store, format FormatFunc) *Poller {
+	return &Poller{
+		npsClient:    npsClient,
+		twilioClient: twilioClient,
+		store:        st,
+		format:       format,
+	}
+}
+
+// Run polls straight away and then every interval until ctx is done.
+func (p *Poller) Run(ctx context.Context, interval time.Duration) {
+
+	logger := logging.FromContext(ctx)
+
+	poll := func() {
+		if err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("failed to poll for new alerts", zap.Error(err))
+		}
+	}
+
+	poll()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
+
+// Poll checks every subscribed topic once. A topic that has never been
+// polled is only recorded, so new subscribers are not sent every alert that
+// is already up. Failures for one topic are logged and do not stop the
+// others; only failing to list topics is returned.
+func (p *Poller) Poll(ctx context.Context) error {
+
+	topics, err := p.store.Topics(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range topics {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p.pollTopic(ctx, topic)
+	}
+
+	return nil
+}
+
+func (p *Poller) pollTopic(ctx context.Context, topic store.Topic) {
+
+	logger := logging.FromContext(ctx).With(zap.Stringer("topic", topic))
+
+	alerts, err := p.fetch(ctx, topic)
+	if err != nil {
+		logger.Error("failed to fetch alerts", zap.Error(err))
+		return
+	}
+
+	seen, polled, err := p.store.SeenAlerts(ctx, topic)
+	if err != nil {
+		logger.Error("failed to load seen alerts", zap.Error(err))
+		return
+	}
+
+	ids := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		ids = append(ids, alert.ID)
+	}
+
+	if polled {
+		fresh := newAlerts(alerts, seen)
+		if len(fresh) > 0 {
+			sent, err := p.notify(ctx, topic, fresh)
+			if err != nil {
+				// alerts that were texted count as seen so they are not
+				// sent again, the rest are tried next time
+				logger.Error("failed to notify subscribers", zap.Error(err))
+				ids = withoutUnsent(ids, fresh, sent)
+			}
+		}
+	}
+
+	saveCtx := ctx
+	if ctx.Err() != nil {
+		// the round was cut short, but what was sent still has to be saved
+		var cancel context.CancelFunc
+		saveCtx, cancel = context.WithTimeout(logging.WithLogger(context.Background(), logger), saveTimeout)
+		defer cancel()
+	}
+
+	if err := p.store.SetSeenAlerts(saveCtx, topic, ids); err != nil {
+		logger.Error("failed to save seen alerts", zap.Error(err))
+	}
+}
+
+func (p *Poller) fetch(ctx context.Context, topic store.Topic) ([]nps.AlertDetails, error) {
+	if topic.Kind == store.TopicState {
+		return p.npsClient.GetAlerts(ctx, topic.Code, nil)
+	}
+	return p.npsClient.GetParkAlerts(ctx, topic.Code, nil)
+}
+
+// notify texts alerts to every subscriber of topic, oldest alert first. A
+// failed text is logged and skipped so one bad number does not hold up the
+// rest; the alerts still count as seen. It returns the IDs of the alerts it
+// got to, which is all of them unless it also returns an error. An alert cut
+// short partway through its subscribers is among them, so the rest of its
+// subscribers miss it rather than the first ones getting it twice.
+func (p *Poller) notify(ctx context.Context, topic store.Topic, alerts []nps.AlertDetails) ([]string, error) {
+
+	logger := logging.FromContext(ctx).With(zap.Stringer("topic", topic))
+
+	subs, err := p.store.Subscribers(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	sent := make([]string, 0, len(alerts))
+	for i := len(alerts) - 1; i >= 0; i-- {
+		alert := alerts[i]
+		for j, sub := range subs {
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+			if j == 0 {
+				sent = append(sent, alert.ID)
+			}
+			message, err := p.format(ctx, sub, alert)
+			if err != nil {
+				logger.Error("failed to render new alert", zap.String("alertID", alert.ID), zap.Error(err))
+				continue
+			}
+			err = p.twilioClient.SendMessage(ctx, sub.Phone, message)
+			if errors.Is(err, twilio.ErrOptedOut) {
+				logger.Debug("skipped opted out subscriber", zap.String("alertID", alert.ID))
+				continue
+			}
+			if err != nil {
+				logger.Error("failed to send new alert", zap.String("alertID", alert.ID), zap.Error(err))
+				continue
+			}
+			logger.Info("sent new alert", zap.String("alertID", alert.ID))
+		}
+	}
+
+	return sent, nil
+}
+
+// withoutUnsent returns ids less the IDs of the fresh alerts that are not in
+// sent, keeping their order.
+func withoutUnsent(ids []string, fresh []nps.AlertDetails, sent []string) []string {
+	unsent := make(map[string]bool, len(fresh))
+	for _, alert := range fresh {
+		unsent[alert.ID] = true
+	}
+	for _, id := range sent {
+		delete(unsent, id)
+	}
+
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !unsent[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// newAlerts returns the alerts whose IDs are not in seen, keeping their
+// order.
+func newAlerts(alerts []nps.AlertDetails, seen []string) []nps.AlertDetails {
+	known := make(map[string]bool, len(seen))
+	for _, id := range seen {
+		known[id] = true
+	}
+
+	fresh := []nps.AlertDetails{}
+	for _, alert := range alerts {
+		if !known[alert.ID] {
+			fresh = append(fresh, alert)
+		}
+	}
+	return fresh
+}
@@ -0,0 +1,345 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/WilliamDeBruin/nps_alerts/src/twilio"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNpsClient serves alerts per state code or park code.
+type fakeNpsClient struct {
+	mu     sync.Mutex
+	alerts map[string][]nps.AlertDetails
+	err    error
+	calls  int
+}
+
+func (f *fakeNpsClient) GetAlert(ctx context.Context, stateCode string) (*nps.AlertDetails, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeNpsClient) GetAlerts(ctx context.Context, stateCode string, opts *nps.AlertOptions) ([]nps.AlertDetails, error) {
+	return f.get(stateCode)
+}
+
+func (f *fakeNpsClient) GetParkAlerts(ctx context.Context, park string, opts *nps.AlertOptions) ([]nps.AlertDetails, error) {
+	return f.get(park)
+}
+
+func (f *fakeNpsClient) SetTransport(http.RoundTripper) {}
+
+func (f *fakeNpsClient) get(key string) ([]nps.AlertDetails, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.alerts[key], nil
+}
+
+func (f *fakeNpsClient) set(key string, alerts ...nps.AlertDetails) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.alerts[key] = alerts
+}
+
+// fakeTwilioClient records every text sent.
+type fakeTwilioClient struct {
+	mu      sync.Mutex
+	sent    []string
+	failFor string
+}
+
+func (f *fakeTwilioClient) SendMessage(ctx context.Context, to, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if to == f.failFor {
+		return errors.New("TEST_SEND_ERR")
+	}
+	f.sent = append(f.sent, fmt.Sprintf("%s: %s", to, message))
+	return nil
+}
+
+func (f *fakeTwilioClient) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.sent...)
+}
+
+var utah = store.Topic{Kind: store.TopicState, Code: "UT"}
+
+func format(ctx context.Context, sub store.Subscription, alert nps.AlertDetails) (string, error) {
+	return fmt.Sprintf("%s %s", sub.Name, alert.AlertHeader), nil
+}
+
+func newTestPoller() (*Poller, *fakeNpsClient, *fakeTwilioClient, *store.Memory) {
+	npsClient := &fakeNpsClient{alerts: map[string][]nps.AlertDetails{}}
+	twilioClient := &fakeTwilioClient{}
+	st := store.NewMemory()
+
+	_, _ = st.Subscribe(context.Background(), store.Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	return New(npsClient, twilioClient, st, format), npsClient, twilioClient, st
+}
+
+func TestPollSeedsFirstPoll(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, st := newTestPoller()
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "OLD"})
+
+	err := p.Poll(context.Background())
+
+	assert.Nil(err)
+	assert.Empty(twilioClient.messages())
+
+	seen, ok, _ := st.SeenAlerts(context.Background(), utah)
+	assert.True(ok)
+	assert.Equal([]string{"1"}, seen)
+}
+
+func TestPollSendsNewAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, st := newTestPoller()
+	_, _ = st.Subscribe(context.Background(), store.Subscription{Phone: "+1666", Topic: utah, Name: "Utah", CreatedAt: time.Now()})
+
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "OLD"})
+	_ = p.Poll(context.Background())
+
+	// alerts come back newest first and are sent oldest first
+	npsClient.set("UT",
+		nps.AlertDetails{ID: "3", AlertHeader: "NEWEST"},
+		nps.AlertDetails{ID: "2", AlertHeader: "NEW"},
+		nps.AlertDetails{ID: "1", AlertHeader: "OLD"},
+	)
+
+	err := p.Poll(context.Background())
+
+	assert.Nil(err)
+	assert.Equal([]string{
+		"+1555: Utah NEW",
+		"+1666: Utah NEW",
+		"+1555: Utah NEWEST",
+		"+1666: Utah NEWEST",
+	}, twilioClient.messages())
+
+	seen, _, _ := st.SeenAlerts(context.Background(), utah)
+	assert.Equal([]string{"3", "2", "1"}, seen)
+
+	// nothing new the next time round
+	_ = p.Poll(context.Background())
+	assert.Len(twilioClient.messages(), 4)
+}
+
+func TestPollSendFailureSkipsSubscriber(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, st := newTestPoller()
+	_, _ = st.Subscribe(context.Background(), store.Subscription{Phone: "+1666", Topic: utah, Name: "Utah", CreatedAt: time.Now()})
+	twilioClient.failFor = "+1555"
+
+	_ = p.Poll(context.Background())
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "NEW"})
+
+	err := p.Poll(context.Background())
+
+	assert.Nil(err)
+	assert.Equal([]string{"+1666: Utah NEW"}, twilioClient.messages())
+
+	seen, _, _ := st.SeenAlerts(context.Background(), utah)
+	assert.Equal([]string{"1"}, seen)
+}
+
+func TestPollFormatFailureSkipsAlert(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &fakeNpsClient{alerts: map[string][]nps.AlertDetails{}}
+	twilioClient := &fakeTwilioClient{}
+	st := store.NewMemory()
+	_, _ = st.Subscribe(context.Background(), store.Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	p := New(npsClient, twilioClient, st, func(ctx context.Context, sub store.Subscription, alert nps.AlertDetails) (string, error) {
+		if alert.ID == "1" {
+			return "", errors.New("TEST_FORMAT_ERR")
+		}
+		return format(ctx, sub, alert)
+	})
+
+	_ = p.Poll(context.Background())
+	npsClient.set("UT",
+		nps.AlertDetails{ID: "2", AlertHeader: "GOOD"},
+		nps.AlertDetails{ID: "1", AlertHeader: "BAD"},
+	)
+
+	err := p.Poll(context.Background())
+
+	assert.Nil(err)
+	assert.Equal([]string{"+1555: Utah GOOD"}, twilioClient.messages())
+}
+
+func TestPollCutShortSavesSent(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, st := newTestPoller()
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "OLD"})
+	_ = p.Poll(context.Background())
+
+	npsClient.set("UT",
+		nps.AlertDetails{ID: "3", AlertHeader: "NEWEST"},
+		nps.AlertDetails{ID: "2", AlertHeader: "NEW"},
+		nps.AlertDetails{ID: "1", AlertHeader: "OLD"},
+	)
+
+	// shut down after the first text goes out
+	ctx, cancel := context.WithCancel(context.Background())
+	p.twilioClient = &cancellingClient{twilioClient, cancel}
+
+	_ = p.Poll(ctx)
+
+	assert.Equal([]string{"+1555: Utah NEW"}, twilioClient.messages())
+
+	seen, _, _ := st.SeenAlerts(context.Background(), utah)
+	assert.Equal([]string{"2", "1"}, seen)
+
+	// the next round only sends what was left
+	p.twilioClient = twilioClient
+	_ = p.Poll(context.Background())
+
+	assert.Equal([]string{"+1555: Utah NEW", "+1555: Utah NEWEST"}, twilioClient.messages())
+}
+
+func TestPollFetchFailureKeepsSeen(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, st := newTestPoller()
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "OLD"})
+	_ = p.Poll(context.Background())
+
+	npsClient.err = nps.ErrUnavailable
+
+	err := p.Poll(context.Background())
+
+	assert.Nil(err)
+	assert.Empty(twilioClient.messages())
+
+	seen, _, _ := st.SeenAlerts(context.Background(), utah)
+	assert.Equal([]string{"1"}, seen)
+}
+
+func TestPollParks(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, st := newTestPoller()
+	yose := store.Topic{Kind: store.TopicPark, Code: "yose"}
+	_, _ = st.Subscribe(context.Background(), store.Subscription{Phone: "+1777", Topic: yose, Name: "Yosemite"})
+
+	_ = p.Poll(context.Background())
+	npsClient.set("yose", nps.AlertDetails{ID: "1", AlertHeader: "TIOGA CLOSED"})
+
+	_ = p.Poll(context.Background())
+
+	assert.Equal([]string{"+1777: Yosemite TIOGA CLOSED"}, twilioClient.messages())
+}
+
+func TestPollDurableAcrossRestarts(t *testing.T) {
+	assert := assert.New(t)
+
+	path := t.TempDir() + "/store.json"
+	st, _ := store.NewFile(path)
+	_, _ = st.Subscribe(context.Background(), store.Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	npsClient := &fakeNpsClient{alerts: map[string][]nps.AlertDetails{}}
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "OLD"})
+
+	_ = New(npsClient, &fakeTwilioClient{}, st, format).Poll(context.Background())
+
+	// a restarted poller loads what was seen and only sends the new alert
+	reloaded, _ := store.NewFile(path)
+	twilioClient := &fakeTwilioClient{}
+	npsClient.set("UT",
+		nps.AlertDetails{ID: "2", AlertHeader: "NEW"},
+		nps.AlertDetails{ID: "1", AlertHeader: "OLD"},
+	)
+
+	_ = New(npsClient, twilioClient, reloaded, format).Poll(context.Background())
+
+	assert.Equal([]string{"+1555: Utah NEW"}, twilioClient.messages())
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, _, _ := newTestPoller()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(func() bool {
+		npsClient.mu.Lock()
+		defer npsClient.mu.Unlock()
+		return npsClient.calls > 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after the context was cancelled")
+	}
+}
+
+func TestPollSkipsOptedOut(t *testing.T) {
+	assert := assert.New(t)
+
+	p, npsClient, twilioClient, _ := newTestPoller()
+	p.twilioClient = &optedOutClient{twilioClient}
+
+	_ = p.Poll(context.Background())
+	npsClient.set("UT", nps.AlertDetails{ID: "1", AlertHeader: "NEW"})
+
+	err := p.Poll(context.Background())
+
+	assert.Nil(err)
+	assert.Empty(twilioClient.messages())
+}
+
+// optedOutClient behaves as if every number had opted out.
+type optedOutClient struct {
+	*fakeTwilioClient
+}
+
+func (o *optedOutClient) SendMessage(ctx context.Context, to, message string) error {
+	return twilio.ErrOptedOut
+}
+
+// cancellingClient cancels a context once it has sent a text.
+type cancellingClient struct {
+	*fakeTwilioClient
+	cancel context.CancelFunc
+}
+
+func (c *cancellingClient) SendMessage(ctx context.Context, to, message string) error {
+	defer c.cancel()
+	return c.fakeTwilioClient.SendMessage(ctx, to, message)
+}
@@ -0,0 +1,371 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPageLimit is how many results a page has when limit is not given.
+	defaultPageLimit = 20
+
+	// maxPageLimit caps the limit a client can ask for.
+	maxPageLimit = 100
+)
+
+// Codes for the "code" field of API error bodies, so clients need not match
+// on messages.
+const (
+	apiErrInvalidParameter = "invalid_parameter"
+	apiErrInvalidState     = "invalid_state"
+	apiErrParkNotFound     = "park_not_found"
+	apiErrUnauthorized     = "unauthorized"
+	apiErrTooManyRequests  = "too_many_requests"
+	apiErrRateLimited      = "rate_limited"
+	apiErrUnavailable      = "unavailable"
+	apiErrUpstream         = "upstream_error"
+	apiErrInternal         = "internal_error"
+)
+
+// apiPage is the envelope of every list response. Total is the number of
+// results across all pages, and Start is the offset of the first in Data.
+type apiPage struct {
+	Total int         `json:"total"`
+	Limit int         `json:"limit"`
+	Start int         `json:"start"`
+	Data  interface{} `json:"data"`
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error apiErrorDetails `json:"error"`
+}
+
+type apiErrorDetails struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Suggestions are the closest parks to a park that was not found.
+	Suggestions []apiParkSuggestion `json:"suggestions,omitempty"`
+}
+
+type apiParkSuggestion struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// apiAlert is an alert as the API returns it.
+type apiAlert struct {
+	ID          string     `json:"id"`
+	ParkCode    string     `json:"parkCode"`
+	ParkName    string     `json:"parkName"`
+	StateCode   string     `json:"stateCode,omitempty"`
+	StateName   string     `json:"stateName,omitempty"`
+	Category    string     `json:"category"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	URL         string     `json:"url,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+}
+
+func newAPIAlert(alert nps.AlertDetails) apiAlert {
+	a := apiAlert{
+		ID:          alert.ID,
+		ParkCode:    strings.ToLower(alert.ParkCode),
+		ParkName:    alert.FullParkName,
+		StateCode:   alert.StateCode,
+		StateName:   alert.FullStateName,
+		Category:    alert.Category,
+		Title:       alert.AlertHeader,
+		Description: alert.AlertMessage,
+		URL:         alert.URL,
+	}
+	if !alert.RecentAlertTime.IsZero() {
+		published := alert.RecentAlertTime.UTC()
+		a.Published = &published
+	}
+	return a
+}
+
+// apiRoutes adds the read-only JSON API to r, behind the rate limit and, when
+// keys are configured, the API key check.
+func (s *Server) apiRoutes(r chi.Router) {
+	if s.apiTrustProxy {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(s.limitAPIRate, s.requireAPIKey)
+
+	r.Get("/alerts", s.apiAlertsHandler)
+	r.Get("/parks", s.apiParksHandler)
+	r.Get("/parks/{code}", s.apiParkHandler)
+}
+
+// apiAlertsHandler lists the current alerts for the states in the state
+// parameter or the park, a code or name, in the park parameter, newest
+// first. Given both, only alerts for a park in one of the states are listed.
+// category narrows the alerts like it does in texts.
+func (s *Server) apiAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	r = r.WithContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	query := r.URL.Query()
+
+	start, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	opts := &nps.AlertOptions{}
+	if c := query.Get("category"); c != "" {
+		category, ok := nps.ParseCategory(c)
+		if !ok {
+			writeAPIError(w, r, http.StatusBadRequest, apiErrInvalidParameter, fmt.Sprintf("unknown category %q", c))
+			return
+		}
+		opts.Categories = []string{category}
+	}
+
+	states, ok := parseStatesParam(w, r)
+	if !ok {
+		return
+	}
+
+	park := query.Get("park")
+	if len(states) == 0 && park == "" {
+		writeAPIError(w, r, http.StatusBadRequest, apiErrInvalidParameter, "state or park is required")
+		return
+	}
+
+	var alerts []nps.AlertDetails
+	var err error
+
+	if park != "" {
+		details, suggestions, ok := s.parks.Resolve(park)
+		if !ok {
+			writeAPIParkNotFound(w, r, park, suggestions)
+			return
+		}
+		if len(states) == 0 || sharesState(details.States, states) {
+			alerts, err = s.npsClient.GetParkAlerts(ctx, details.Code, opts)
+		}
+	} else {
+		alerts, err = s.npsClient.GetAlerts(ctx, strings.Join(states, ","), opts)
+	}
+
+	if err != nil {
+		status, code, message := apiErrorStatus(err)
+		if status >= http.StatusInternalServerError {
+			logger.Error("failed to look up alerts", zap.Error(err))
+		}
+		writeAPIError(w, r, status, code, message)
+		return
+	}
+
+	data := make([]apiAlert, 0, limit)
+	for _, i := range paginate(len(alerts), start, limit) {
+		data = append(data, newAPIAlert(alerts[i]))
+	}
+
+	writeJSON(w, r, http.StatusOK, apiPage{Total: len(alerts), Limit: limit, Start: start, Data: data})
+}
+
+// apiParksHandler lists the parks in the catalog by code, only those in the
+// states given in the state parameter when it is set.
+func (s *Server) apiParksHandler(w http.ResponseWriter, r *http.Request) {
+	start, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	states, ok := parseStatesParam(w, r)
+	if !ok {
+		return
+	}
+
+	registry := s.parks.Registry()
+	parks := registry.Parks()
+
+	if len(states) > 0 {
+		// a park in several of the states is listed once
+		parks = nil
+		listed := map[string]bool{}
+		for _, state := range states {
+			for _, p := range registry.ParksInState(state) {
+				if !listed[p.Code] {
+					listed[p.Code] = true
+					parks = append(parks, p)
+				}
+			}
+		}
+		sort.Slice(parks, func(i, j int) bool { return parks[i].Code < parks[j].Code })
+	}
+
+	data := make([]nps.Park, 0, limit)
+	for _, i := range paginate(len(parks), start, limit) {
+		data = append(data, parks[i])
+	}
+
+	writeJSON(w, r, http.StatusOK, apiPage{Total: len(parks), Limit: limit, Start: start, Data: data})
+}
+
+// apiParkHandler returns the park with the code in the path. Unlike the
+// park parameter of alerts, only an exact code matches.
+func (s *Server) apiParkHandler(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	park, ok := s.parks.Park(code)
+	if !ok {
+		_, suggestions, _ := s.parks.Resolve(code)
+		writeAPIParkNotFound(w, r, code, suggestions)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, park)
+}
+
+// parsePage reads the start and limit parameters, writing an error response
+// and returning false when either is not a number in range.
+func parsePage(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	query := r.URL.Query()
+
+	start, limit := 0, defaultPageLimit
+
+	if param := query.Get("start"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 0 {
+			writeAPIError(w, r, http.StatusBadRequest, apiErrInvalidParameter, "start must be a number of at least 0")
+			return 0, 0, false
+		}
+		start = n
+	}
+
+	if param := query.Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > maxPageLimit {
+			writeAPIError(w, r, http.StatusBadRequest, apiErrInvalidParameter, fmt.Sprintf("limit must be a number from 1 to %d", maxPageLimit))
+			return 0, 0, false
+		}
+		limit = n
+	}
+
+	return start, limit, true
+}
+
+// parseStatesParam reads the state parameter, a comma separated list of
+// state codes or names, writing an error response and returning false when
+// one is not a state.
+func parseStatesParam(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	param := r.URL.Query().Get("state")
+	if param == "" {
+		return nil, true
+	}
+
+	var states []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		code, ok := nps.ResolveState(name)
+		if !ok {
+			writeAPIError(w, r, http.StatusBadRequest, apiErrInvalidState, fmt.Sprintf("unknown state %q", name))
+			return nil, false
+		}
+		states = append(states, code)
+	}
+	return states, true
+}
+
+// paginate returns the indexes of the page of total results that begins at
+// start and has up to limit results.
+func paginate(total, start, limit int) []int {
+	if start >= total {
+		return nil
+	}
+
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	indexes := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// sharesState reports whether any of a park's states is one of states.
+func sharesState(parkStates, states []string) bool {
+	for _, p := range parkStates {
+		for _, s := range states {
+			if strings.EqualFold(p, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apiErrorStatus maps an error from the NPS client to the status, error code
+// and message of the response, the way npsErrorReply does for texts. Only
+// errors in the request are described in the message.
+func apiErrorStatus(err error) (int, string, string) {
+	var statusErr *nps.StatusError
+	var decodeErr *nps.DecodeError
+
+	switch {
+	case errors.Is(err, nps.ErrInvalidState):
+		return http.StatusBadRequest, apiErrInvalidState, err.Error()
+	case errors.Is(err, nps.ErrUnknownPark):
+		return http.StatusNotFound, apiErrParkNotFound, err.Error()
+	case errors.Is(err, nps.ErrRateLimited):
+		return http.StatusServiceUnavailable, apiErrRateLimited, "NPS is rate limiting requests, try again later"
+	case errors.Is(err, nps.ErrUnavailable):
+		return http.StatusServiceUnavailable, apiErrUnavailable, "NPS is unavailable, try again later"
+	case errors.As(err, &statusErr), errors.As(err, &decodeErr):
+		return http.StatusBadGateway, apiErrUpstream, "NPS sent an unexpected response"
+	default:
+		return http.StatusInternalServerError, apiErrInternal, "something went wrong while looking up alerts"
+	}
+}
+
+func writeAPIParkNotFound(w http.ResponseWriter, r *http.Request, query string, suggestions []nps.Park) {
+	body := apiError{Error: apiErrorDetails{
+		Code:    apiErrParkNotFound,
+		Message: fmt.Sprintf("no park matches %q", query),
+	}}
+	for _, p := range suggestions {
+		body.Error.Suggestions = append(body.Error.Suggestions, apiParkSuggestion{Code: strings.ToLower(p.Code), Name: p.Name})
+	}
+	writeJSON(w, r, http.StatusNotFound, body)
+}
+
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeJSON(w, r, status, apiError{Error: apiErrorDetails{Code: code, Message: message}})
+}
+
+// writeJSON writes v as the JSON body of a response with status.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to encode response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
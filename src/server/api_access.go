@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyHeader carries the key of a JSON API client.
+const apiKeyHeader = "X-API-Key"
+
+// requireAPIKey rejects JSON API requests without one of the configured keys
+// with 401. With no keys configured the API is open.
+func (s *Server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.apiKeys) > 0 && !s.validAPIKey(r.Header.Get(apiKeyHeader)) {
+			writeAPIError(w, r, http.StatusUnauthorized, apiErrUnauthorized, fmt.Sprintf("a valid %s header is required", apiKeyHeader))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, k := range s.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// limitAPIRate rejects JSON API requests from a client that is over its rate
+// limit with 429 and a Retry-After header. It runs before the key is checked
+// so guessing keys is limited too.
+func (s *Server) limitAPIRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.apiLimiter != nil {
+			if ok, wait := s.apiLimiter.allow(s.apiClient(r)); !ok {
+				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+				writeAPIError(w, r, http.StatusTooManyRequests, apiErrTooManyRequests, "too many requests, try again later")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiClient identifies the client of a JSON API request for rate limiting:
+// by its API key when the key is valid, or else by its address.
+func (s *Server) apiClient(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); s.validAPIKey(key) {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + strings.TrimSpace(host)
+}
+
+// rateLimiter is a token bucket per client: each client can make a burst of
+// perMinute requests, and gets them back at perMinute a minute.
+type rateLimiter struct {
+	perMinute int
+	now       func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests a minute per
+// client, or nil when perMinute is zero or less, which is no limit.
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		perMinute: perMinute,
+		now:       time.Now,
+		clients:   map[string]*bucket{},
+	}
+}
+
+// allow takes a request from client's bucket. When the bucket is empty it
+// returns false and how long until a request is allowed again.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	rate := float64(l.perMinute) / time.Minute.Seconds()
+
+	// a bucket left alone for a minute is full again, so forgetting it
+	// changes nothing and keeps the map from growing
+	if now.Sub(l.lastSweep) >= time.Minute {
+		for c, b := range l.clients {
+			if now.Sub(b.updated) >= time.Minute {
+				delete(l.clients, c)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.perMinute), updated: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = math.Min(float64(l.perMinute), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
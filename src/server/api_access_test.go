@@ -0,0 +1,155 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyRequired(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+	s.apiKeys = []string{"TEST_KEY", "OTHER_KEY"}
+
+	w := apiGetWithKey(s, "/api/v1/parks", "")
+
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	body := apiError{}
+	decodeBody(t, w, &body)
+	assert.Equal(apiErrUnauthorized, body.Error.Code)
+
+	w = apiGetWithKey(s, "/api/v1/parks", "WRONG_KEY")
+
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	w = apiGetWithKey(s, "/api/v1/parks", "OTHER_KEY")
+
+	assert.Equal(http.StatusOK, w.Code)
+}
+
+func TestAPIOpenWithoutKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGetWithKey(s, "/api/v1/parks", "")
+
+	assert.Equal(http.StatusOK, w.Code)
+}
+
+func TestAPIRateLimited(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	s.apiLimiter = newRateLimiter(2)
+
+	for i := 0; i < 2; i++ {
+		w := apiGet(s, "/api/v1/alerts?state=CA")
+		assert.Equal(http.StatusOK, w.Code)
+	}
+	npsClient.lastStateCode = ""
+
+	w := apiGet(s, "/api/v1/alerts?state=CA")
+
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Equal("30", w.Header().Get("Retry-After"))
+	assert.Empty(npsClient.lastStateCode)
+
+	body := apiError{}
+	decodeBody(t, w, &body)
+	assert.Equal(apiErrTooManyRequests, body.Error.Code)
+}
+
+func TestAPIRateLimitPerKey(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+	s.apiKeys = []string{"TEST_KEY", "OTHER_KEY"}
+	s.apiLimiter = newRateLimiter(1)
+
+	assert.Equal(http.StatusOK, apiGetWithKey(s, "/api/v1/parks", "TEST_KEY").Code)
+	assert.Equal(http.StatusTooManyRequests, apiGetWithKey(s, "/api/v1/parks", "TEST_KEY").Code)
+	assert.Equal(http.StatusOK, apiGetWithKey(s, "/api/v1/parks", "OTHER_KEY").Code)
+}
+
+func TestAPIRateLimitBehindProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	get := func(s *Server, forwardedFor string) int {
+		router := chi.NewRouter()
+		router.Route("/api/v1", s.apiRoutes)
+
+		r := httptest.NewRequest("GET", "/api/v1/parks", nil)
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	s := newTestServer(t)
+	s.apiLimiter = newRateLimiter(1)
+
+	assert.Equal(http.StatusOK, get(s, "203.0.113.1"))
+	assert.Equal(http.StatusTooManyRequests, get(s, "203.0.113.2"))
+
+	s = newTestServer(t)
+	s.apiLimiter = newRateLimiter(1)
+	s.apiTrustProxy = true
+
+	assert.Equal(http.StatusOK, get(s, "203.0.113.1"))
+	assert.Equal(http.StatusOK, get(s, "203.0.113.2"))
+	assert.Equal(http.StatusTooManyRequests, get(s, "203.0.113.1"))
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 60; i++ {
+		ok, _ := l.allow("TEST_CLIENT")
+		assert.True(ok)
+	}
+
+	ok, wait := l.allow("TEST_CLIENT")
+	assert.False(ok)
+	assert.Equal(time.Second, wait)
+
+	now = now.Add(time.Second)
+	ok, _ = l.allow("TEST_CLIENT")
+	assert.True(ok)
+
+	ok, _ = l.allow("OTHER_CLIENT")
+	assert.True(ok)
+}
+
+func TestRateLimiterSweepsIdleClients(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60)
+	l.now = func() time.Time { return now }
+
+	_, _ = l.allow("TEST_CLIENT")
+	now = now.Add(2 * time.Minute)
+	_, _ = l.allow("OTHER_CLIENT")
+
+	assert.Len(l.clients, 1)
+	assert.Contains(l.clients, "OTHER_CLIENT")
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newRateLimiter(0))
+}
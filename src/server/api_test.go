@@ -0,0 +1,357 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAlertsPage struct {
+	Total int        `json:"total"`
+	Limit int        `json:"limit"`
+	Start int        `json:"start"`
+	Data  []apiAlert `json:"data"`
+}
+
+type testParksPage struct {
+	Total int        `json:"total"`
+	Limit int        `json:"limit"`
+	Start int        `json:"start"`
+	Data  []nps.Park `json:"data"`
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid JSON body %q: %s", w.Body.String(), err)
+	}
+}
+
+func TestAPIAlertsByState(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{
+		ID:              "TEST_ID",
+		ParkCode:        "YOSE",
+		FullParkName:    "Yosemite",
+		StateCode:       "CA",
+		FullStateName:   "California",
+		Category:        nps.CategoryClosure,
+		RecentAlertTime: time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC),
+		AlertHeader:     "TEST_HEADER",
+		AlertMessage:    "TEST_MESSAGE",
+		URL:             "TEST_URL",
+	}}
+
+	w := apiGet(s, "/api/v1/alerts?state=CA&category=closure")
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+	assert.Equal("CA", npsClient.lastStateCode)
+	assert.Equal([]string{nps.CategoryClosure}, npsClient.lastOpts.Categories)
+	assert.Contains(w.Body.String(), `"published":"2022-06-07T17:55:48Z"`)
+
+	page := testAlertsPage{}
+	decodeBody(t, w, &page)
+
+	assert.Equal(1, page.Total)
+	assert.Equal(defaultPageLimit, page.Limit)
+	assert.Equal(0, page.Start)
+	assert.Len(page.Data, 1)
+	assert.Equal("TEST_ID", page.Data[0].ID)
+	assert.Equal("yose", page.Data[0].ParkCode)
+	assert.Equal("Yosemite", page.Data[0].ParkName)
+	assert.Equal("California", page.Data[0].StateName)
+	assert.Equal("TEST_HEADER", page.Data[0].Title)
+	assert.Equal("TEST_MESSAGE", page.Data[0].Description)
+}
+
+func TestAPIAlertsStateNames(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+
+	w := apiGet(s, "/api/v1/alerts?state=utah,new%20mexico")
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("UT,NM", npsClient.lastStateCode)
+	assert.Equal(`{"total":0,"limit":20,"start":0,"data":[]}`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestAPIAlertsByPark(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+
+	w := apiGet(s, "/api/v1/alerts?state=CA&park=yose")
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("yose", strings.ToLower(npsClient.lastPark))
+	assert.Empty(npsClient.lastStateCode)
+}
+
+func TestAPIAlertsParkOutsideState(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{ID: "TEST_ID"}}
+
+	w := apiGet(s, "/api/v1/alerts?state=NV&park=yose")
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Empty(npsClient.lastPark)
+
+	page := testAlertsPage{}
+	decodeBody(t, w, &page)
+	assert.Equal(0, page.Total)
+	assert.Empty(page.Data)
+}
+
+func TestAPIAlertsPagination(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	for i := 0; i < 25; i++ {
+		npsClient.getAlertsResponse = append(npsClient.getAlertsResponse, nps.AlertDetails{ID: fmt.Sprint(i)})
+	}
+
+	w := apiGet(s, "/api/v1/alerts?state=CA&start=20&limit=10")
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	page := testAlertsPage{}
+	decodeBody(t, w, &page)
+	assert.Equal(25, page.Total)
+	assert.Equal(10, page.Limit)
+	assert.Equal(20, page.Start)
+	assert.Len(page.Data, 5)
+	assert.Equal("20", page.Data[0].ID)
+}
+
+func TestAPIAlertsPastLastPage(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{ID: "TEST_ID"}}
+
+	w := apiGet(s, "/api/v1/alerts?state=CA&start=5")
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	page := testAlertsPage{}
+	decodeBody(t, w, &page)
+	assert.Equal(1, page.Total)
+	assert.Empty(page.Data)
+}
+
+func TestAPIAlertsMissingFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/alerts")
+
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Equal(`{"error":{"code":"invalid_parameter","message":"state or park is required"}}`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestAPIAlertsInvalidState(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/alerts?state=CA,XX")
+
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Equal(`{"error":{"code":"invalid_state","message":"unknown state \"XX\""}}`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestAPIAlertsInvalidCategory(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/alerts?state=CA&category=weather")
+
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	body := apiError{}
+	decodeBody(t, w, &body)
+	assert.Equal(apiErrInvalidParameter, body.Error.Code)
+}
+
+func TestAPIAlertsInvalidLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=ten", "start=-1"} {
+		w := apiGet(s, "/api/v1/alerts?state=CA&"+query)
+
+		assert.Equal(http.StatusBadRequest, w.Code, query)
+
+		body := apiError{}
+		decodeBody(t, w, &body)
+		assert.Equal(apiErrInvalidParameter, body.Error.Code, query)
+	}
+}
+
+func TestAPIAlertsUnknownPark(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/alerts?park=grand")
+
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	body := apiError{}
+	decodeBody(t, w, &body)
+	assert.Equal(apiErrParkNotFound, body.Error.Code)
+	assert.NotEmpty(body.Error.Suggestions)
+}
+
+func TestAPIAlertsUnavailable(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsErr = &nps.StatusError{StatusCode: http.StatusBadGateway}
+
+	w := apiGet(s, "/api/v1/alerts?state=CA")
+
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	assert.Equal(`{"error":{"code":"unavailable","message":"NPS is unavailable, try again later"}}`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestAPIAlertsUpstreamError(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsErr = &nps.StatusError{StatusCode: http.StatusForbidden}
+
+	w := apiGet(s, "/api/v1/alerts?state=CA")
+
+	assert.Equal(http.StatusBadGateway, w.Code)
+
+	body := apiError{}
+	decodeBody(t, w, &body)
+	assert.Equal(apiErrUpstream, body.Error.Code)
+	assert.NotContains(body.Error.Message, "403")
+}
+
+func TestAPIParks(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/parks")
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	page := testParksPage{}
+	decodeBody(t, w, &page)
+	assert.Equal(len(s.parks.Parks()), page.Total)
+	assert.Len(page.Data, defaultPageLimit)
+	assert.Equal(s.parks.Parks()[0], page.Data[0])
+}
+
+func TestAPIParksByState(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/parks?state=wyoming&limit=100")
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	page := testParksPage{}
+	decodeBody(t, w, &page)
+	assert.NotEmpty(page.Data)
+	assert.Equal(len(page.Data), page.Total)
+	for _, p := range page.Data {
+		assert.Contains(p.States, "WY", p.Code)
+	}
+}
+
+func TestAPIParksInSeveralStates(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/parks?state=WY,MT&limit=100")
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	page := testParksPage{}
+	decodeBody(t, w, &page)
+
+	codes := []string{}
+	for _, p := range page.Data {
+		codes = append(codes, p.Code)
+	}
+	assert.True(sort.StringsAreSorted(codes))
+
+	yell := 0
+	for _, code := range codes {
+		if code == "yell" {
+			yell++
+		}
+	}
+	assert.Equal(1, yell)
+}
+
+func TestAPIParksInvalidState(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/parks?state=XX")
+
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestAPIPark(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/parks/YOSE")
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	park := nps.Park{}
+	decodeBody(t, w, &park)
+	assert.Equal("yose", park.Code)
+	assert.Contains(park.States, "CA")
+}
+
+func TestAPIParkNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t)
+
+	w := apiGet(s, "/api/v1/parks/yosemite")
+
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	body := apiError{}
+	decodeBody(t, w, &body)
+	assert.Equal(apiErrParkNotFound, body.Error.Code)
+	assert.Equal(`no park matches "yosemite"`, body.Error.Message)
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+)
+
+// command is something texters can ask for, such as "alerts UT". The first
+// word of a text picks the command and the rest are its arguments.
+type command struct {
+	name string
+
+	// aliases are other first words that run the command, like "alert" for
+	// "alerts"
+	aliases []string
+
+	// translations are the words that run the command in other languages,
+	// the first of each listed in that language's help text. A text
+	// starting with one of them is answered in its language.
+	translations map[string][]string
+
+	// exact commands only run when the text is nothing but the command word.
+	// The carrier opt-out keywords are exact so that "unsubscribe UT" removes
+	// one subscription rather than opting out of everything.
+	exact bool
+
+	// usage is the command's lines in the help text, in order
+	usage []string
+
+	handler func(s *Server, w http.ResponseWriter, r *http.Request, args []string)
+}
+
+func (c command) matches(name string) bool {
+	if c.name == name {
+		return true
+	}
+	for _, alias := range c.aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return c.language(name) != ""
+}
+
+// language returns the language of the translation name is, or nothing
+// when it is not one.
+func (c command) language(name string) string {
+	for lang, words := range c.translations {
+		for _, word := range words {
+			if word == name {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// commands are listed in the order they appear in the help text.
+var commands []command
+
+// The table is filled in by init because the help command's handler lists
+// the table.
+func init() {
+	commands = []command{
+		{
+			name:         "help",
+			aliases:      []string{"info", "?", "commands"},
+			translations: map[string][]string{i18n.Spanish: {"ayuda"}},
+			usage:        []string{"Help: receive this help text"},
+			handler:      (*Server).helpHandler,
+		},
+		{
+			name:         "alerts",
+			aliases:      []string{"alert"},
+			translations: map[string][]string{i18n.Spanish: {"alertas", "alerta"}},
+			usage: []string{
+				`Alerts {state}: Text "alerts" followed by a state code or name, like "alerts UT" or "alerts new mexico". List several states to see the newest alert of each, like "alerts CA NV AZ"`,
+				`Alerts {park}: Text "alerts" followed by a park code or park name, like "alerts yose" or "alerts yosemite"`,
+				`Alerts: Text just "alerts" to see alerts for your home state, or the state your number is from`,
+				`Add danger, caution, closures or info to the end of an alerts text to only see that kind of alert, like "alerts CA closures"`,
+			},
+			handler: (*Server).alertHandler,
+		},
+		{
+			name:         "more",
+			aliases:      []string{"next"},
+			translations: map[string][]string{i18n.Spanish: {"más", "mas", "siguiente"}},
+			usage:        []string{`More: when a state or park has several alerts, "alerts" lists their headlines. Text "more" for the next page`},
+			handler:      (*Server).moreHandler,
+		},
+		{
+			name:         "read",
+			translations: map[string][]string{i18n.Spanish: {"leer"}},
+			usage:        []string{`Read {number}: read an alert from the list in full, like "read 3"`},
+			handler:      (*Server).readHandler,
+		},
+		{
+			name:         "home",
+			translations: map[string][]string{i18n.Spanish: {"estado", "casa"}},
+			usage:        []string{`Home {state}: Text "home" followed by a 2-letter state code to save your home state, like "home UT"`},
+			handler:      (*Server).homeHandler,
+		},
+		{
+			name:         "subscribe",
+			aliases:      []string{"sub", "follow"},
+			translations: map[string][]string{i18n.Spanish: {"suscribir", "suscribirse", "seguir"}},
+			usage:        []string{`Subscribe {state or park}: get new alerts texted to you as they're posted, like "subscribe UT" or "subscribe yose"`},
+			handler:      (*Server).subscribeHandler,
+		},
+		{
+			name:         "unsubscribe",
+			aliases:      []string{"unsub", "unfollow"},
+			translations: map[string][]string{i18n.Spanish: {"desuscribir", "desuscribirse"}},
+			usage:        []string{"Unsubscribe {state or park}: stop getting new alerts"},
+			handler:      (*Server).unsubscribeHandler,
+		},
+		{
+			name:         "list",
+			aliases:      []string{"subscriptions"},
+			translations: map[string][]string{i18n.Spanish: {"lista", "suscripciones"}},
+			usage:        []string{"List: see what you're subscribed to"},
+			handler:      (*Server).listHandler,
+		},
+		{
+			name:         "lang",
+			aliases:      []string{"language"},
+			translations: map[string][]string{i18n.Spanish: {"idioma"}},
+			usage:        []string{`Lang {language}: Text "lang es" or "español" for replies in Spanish, or "lang en" or "english" for English`},
+			handler:      (*Server).languageHandler,
+		},
+		{
+			name:    "español",
+			aliases: []string{"espanol", "spanish"},
+			exact:   true,
+			handler: languageSetter(i18n.Spanish),
+		},
+		{
+			name:    "english",
+			aliases: []string{"inglés", "ingles"},
+			exact:   true,
+			handler: languageSetter(i18n.English),
+		},
+		{
+			name:    "stop",
+			aliases: []string{"stopall", "unsubscribe", "cancel", "end", "quit"},
+			exact:   true,
+			usage:   []string{`Stop: stop all texts from NPS alerts. Text "start" to opt back in`},
+			handler: (*Server).optOutHandler,
+		},
+		{
+			name:    "start",
+			aliases: []string{"unstop"},
+			exact:   true,
+			handler: (*Server).optInHandler,
+		},
+	}
+}
+
+// helpFor returns what the help template lists commands from, in lang.
+// Commands without usage, like "start", are left out.
+func helpFor(commands []command, lang string) helpData {
+	data := helpData{}
+	for _, c := range commands {
+		if len(c.usage) == 0 {
+			continue
+		}
+
+		help := helpCommand{Name: c.name, Aliases: c.aliases}
+		if words := c.translations[lang]; len(words) > 0 {
+			help.Name, help.Aliases = words[0], words[1:]
+		}
+		for _, line := range c.usage {
+			help.Usage = append(help.Usage, i18n.Translate(lang, line))
+		}
+
+		data.Commands = append(data.Commands, help)
+	}
+	return data
+}
+
+// route returns the command for a text starting with name, followed by args.
+// An exact command wins when there are no arguments.
+func route(name string, args []string) (command, bool) {
+	if len(args) == 0 {
+		for _, c := range commands {
+			if c.exact && c.matches(name) {
+				return c, true
+			}
+		}
+	}
+	for _, c := range commands {
+		if !c.exact && c.matches(name) {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// parseCommand splits a text into its lower-cased command word and its
+// arguments, which keep their case so replies can quote them.
+func parseCommand(body string) (string, []string) {
+	words := tokenize(body)
+	if len(words) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(words[0]), words[1:]
+}
+
+// tokenize splits a text into words at spaces and commas and trims the
+// punctuation texters put around words, so "Help!" is "Help" and "CA, NV" is
+// "CA" and "NV". A word that is only punctuation, like "?", is kept whole.
+func tokenize(body string) []string {
+	fields := strings.FieldsFunc(body, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return unicode.IsPunct(r) || unicode.IsSymbol(r)
+		})
+		if word == "" {
+			word = field
+		}
+		words = append(words, word)
+	}
+	return words
+}
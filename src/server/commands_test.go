@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"Help"}, tokenize("Help!"))
+	assert.Equal([]string{"alerts", "CA", "NV", "AZ"}, tokenize("  alerts\tCA,NV , AZ. "))
+	assert.Equal([]string{"alerts", "Bryce", "Canyon"}, tokenize(`alerts "Bryce Canyon"`))
+	assert.Equal([]string{"?"}, tokenize("?"))
+	assert.Empty(tokenize(" , "))
+}
+
+func TestParseCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	name, args := parseCommand("ALERTS Ca closures")
+	assert.Equal("alerts", name)
+	assert.Equal([]string{"Ca", "closures"}, args)
+
+	name, args = parseCommand("")
+	assert.Equal("", name)
+	assert.Empty(args)
+}
+
+func TestRouteAlias(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd, ok := route("alert", []string{"CA"})
+	assert.True(ok)
+	assert.Equal("alerts", cmd.name)
+
+	cmd, ok = route("?", nil)
+	assert.True(ok)
+	assert.Equal("help", cmd.name)
+}
+
+func TestRouteExact(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd, ok := route("unsubscribe", nil)
+	assert.True(ok)
+	assert.Equal("stop", cmd.name)
+
+	cmd, ok = route("unsubscribe", []string{"UT"})
+	assert.True(ok)
+	assert.Equal("unsubscribe", cmd.name)
+
+	_, ok = route("stop", []string{"please"})
+	assert.False(ok)
+}
+
+func TestRouteUnknown(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ok := route("weather", []string{"UT"})
+	assert.False(ok)
+}
+
+// testHelpMessage renders the help text with the built-in templates.
+func testHelpMessage(t *testing.T) string {
+	message, err := defaultReplyTemplates.render(i18n.English, helpTemplate, helpFor(commands, i18n.English))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestHelpMessageListsCommands(t *testing.T) {
+	assert := assert.New(t)
+
+	helpMessage := testHelpMessage(t)
+
+	assert.True(strings.HasPrefix(helpMessage, "Welcome to NPS alerts! Here is a list of commands:\n\nHelp: receive this help text\n\nAlerts {state}:"))
+	for _, c := range commands {
+		for _, line := range c.usage {
+			assert.Contains(helpMessage, line)
+		}
+	}
+	assert.NotContains(helpMessage, "Start")
+}
+
+func TestIncomingSmsHelpPunctuation(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("Help!"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(testHelpMessage(t), twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertsCaseAndSpacing(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{FullStateName: "California"}}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("  Alert   ca  Closures. "))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("CA", npsClient.lastStateCode)
+	assert.Equal([]string{nps.CategoryClosure}, npsClient.lastOpts.Categories)
+}
+
+func TestIncomingSmsSubscribeAlias(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	s := newTestServer(t, withStore(st))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("Follow UT"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+
+	subs, _ := st.Subscribers(context.Background(), store.Topic{Kind: store.TopicState, Code: "UT"})
+	assert.Len(subs, 1)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+)
+
+const (
+	invalidStateMessage  = `I'm sorry, "%s" isn't a state code I recognize. Please text "alerts" followed by a 2-letter state code, like "alerts UT"`
+	unknownTargetMessage = `I'm sorry, I couldn't find a state or park matching "%s". Please text "alerts {state}" or "alerts {park}" for recent alerts`
+	didYouMeanMessage    = `I'm sorry, I couldn't find a state or park matching "%s". Did you mean %s?`
+	rateLimitedMessage   = "NPS alerts is very busy right now, please try again in a few minutes."
+	unavailableMessage   = "NPS is unavailable right now, please try again later."
+	internalErrorMessage = "I'm sorry, something went wrong while looking up alerts. Please try again later."
+)
+
+// npsErrorReply maps an error from the NPS client to the reply sent to the
+// texter in lang and the status code returned to Twilio. Problems with what
+// the user typed are 4xx, problems talking to NPS are 5xx.
+func npsErrorReply(lang string, err error, target string) (string, int) {
+	var statusErr *nps.StatusError
+	var decodeErr *nps.DecodeError
+	var unknownParkErr *nps.UnknownParkError
+
+	switch {
+	case errors.Is(err, nps.ErrInvalidState):
+		return i18n.Sprintf(lang, invalidStateMessage, target), http.StatusBadRequest
+	case errors.As(err, &unknownParkErr) && len(unknownParkErr.Suggestions) > 0:
+		return i18n.Sprintf(lang, didYouMeanMessage, target, suggestionList(lang, unknownParkErr.Suggestions)), http.StatusBadRequest
+	case errors.Is(err, nps.ErrUnknownPark):
+		return i18n.Sprintf(lang, unknownTargetMessage, target), http.StatusBadRequest
+	case errors.Is(err, nps.ErrNoAlerts):
+		return i18n.Sprintf(lang, noAlertsMessage, formatAlertsLabel(lang, ""), target), http.StatusOK
+	case errors.Is(err, nps.ErrRateLimited):
+		return i18n.Translate(lang, rateLimitedMessage), http.StatusServiceUnavailable
+	case errors.Is(err, nps.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		// NPS too slow to answer in time is as good as down
+		return i18n.Translate(lang, unavailableMessage), http.StatusServiceUnavailable
+	case errors.As(err, &statusErr), errors.As(err, &decodeErr):
+		return i18n.Translate(lang, internalErrorMessage), http.StatusBadGateway
+	default:
+		return i18n.Translate(lang, internalErrorMessage), http.StatusInternalServerError
+	}
+}
+
+// suggestionList phrases parks as a choice for the texter, e.g.
+// `"Grand Canyon" (grca) or "Grand Teton" (grte)`.
+func suggestionList(lang string, parks []nps.Park) string {
+	names := make([]string, 0, len(parks))
+	for _, p := range parks {
+		names = append(names, fmt.Sprintf("%q (%s)", p.Name, p.Code))
+	}
+
+	return joinList(names, i18n.Translate(lang, "or"))
+}
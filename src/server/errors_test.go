@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNpsErrorReplyInvalidState(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, fmt.Errorf("state code MV is not a valid state code: %w", nps.ErrInvalidState), "MV")
+
+	assert.Equal(`I'm sorry, "MV" isn't a state code I recognize. Please text "alerts" followed by a 2-letter state code, like "alerts UT"`, message)
+	assert.Equal(http.StatusBadRequest, status)
+}
+
+func TestNpsErrorReplyUnknownPark(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, nps.ErrUnknownPark, "yosemity")
+
+	assert.Equal(`I'm sorry, I couldn't find a state or park matching "yosemity". Please text "alerts {state}" or "alerts {park}" for recent alerts`, message)
+	assert.Equal(http.StatusBadRequest, status)
+}
+
+func TestNpsErrorReplyUnknownParkSuggestions(t *testing.T) {
+	assert := assert.New(t)
+
+	err := &nps.UnknownParkError{
+		Query: "grand",
+		Suggestions: []nps.Park{
+			{Code: "grca", Name: "Grand Canyon"},
+			{Code: "grpo", Name: "Grand Portage"},
+			{Code: "grte", Name: "Grand Teton"},
+		},
+	}
+
+	message, status := npsErrorReply(i18n.English, err, "grand")
+
+	assert.Equal(`I'm sorry, I couldn't find a state or park matching "grand". Did you mean "Grand Canyon" (grca), "Grand Portage" (grpo) or "Grand Teton" (grte)?`, message)
+	assert.Equal(http.StatusBadRequest, status)
+}
+
+func TestNpsErrorReplyUnknownParkNoSuggestions(t *testing.T) {
+	assert := assert.New(t)
+
+	message, _ := npsErrorReply(i18n.English, &nps.UnknownParkError{Query: "xyzzy"}, "xyzzy")
+
+	assert.Equal(`I'm sorry, I couldn't find a state or park matching "xyzzy". Please text "alerts {state}" or "alerts {park}" for recent alerts`, message)
+}
+
+func TestNpsErrorReplyNoAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, nps.ErrNoAlerts, "CA")
+
+	assert.Equal("There are no current NPS alerts for CA.", message)
+	assert.Equal(http.StatusOK, status)
+}
+
+func TestNpsErrorReplyRateLimited(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, &nps.StatusError{StatusCode: http.StatusTooManyRequests}, "CA")
+
+	assert.Equal(rateLimitedMessage, message)
+	assert.Equal(http.StatusServiceUnavailable, status)
+}
+
+func TestNpsErrorReplyUnavailable(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, &nps.StatusError{StatusCode: http.StatusBadGateway}, "CA")
+
+	assert.Equal(unavailableMessage, message)
+	assert.Equal(http.StatusServiceUnavailable, status)
+}
+
+func TestNpsErrorReplyDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, fmt.Errorf("nps request: %w", context.DeadlineExceeded), "CA")
+
+	assert.Equal(unavailableMessage, message)
+	assert.Equal(http.StatusServiceUnavailable, status)
+}
+
+func TestNpsErrorReplyUpstreamFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, &nps.StatusError{StatusCode: http.StatusForbidden}, "CA")
+
+	assert.Equal(internalErrorMessage, message)
+	assert.Equal(http.StatusBadGateway, status)
+
+	message, status = npsErrorReply(i18n.English, &nps.DecodeError{Err: errors.New("unexpected EOF")}, "CA")
+
+	assert.Equal(internalErrorMessage, message)
+	assert.Equal(http.StatusBadGateway, status)
+}
+
+func TestNpsErrorReplyUnexpected(t *testing.T) {
+	assert := assert.New(t)
+
+	message, status := npsErrorReply(i18n.English, errors.New("TEST_ERR"), "CA")
+
+	assert.Equal(internalErrorMessage, message)
+	assert.Equal(http.StatusInternalServerError, status)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/sms"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"go.uber.org/zap"
+)
+
+const (
+	statesAlertMessage = "Here are the most recent NPS %s for %s:\n\n%s"
+	stateAlertSection  = "%s, from %s, published %s:\n%s\n%s"
+	stateNoAlerts      = "%s: no current %s"
+
+	// alertsLabel and categoryAlertsLabel name the alerts a reply is about,
+	// all of them or those in one category
+	alertsLabel         = "alerts"
+	categoryAlertsLabel = "%s alerts"
+)
+
+// formatAlert renders the full text of one alert found for a state, when
+// isState is set, or a park, narrowed to category, in lang.
+func (s *Server) formatAlert(lang string, alert nps.AlertDetails, isState bool, category string) (string, error) {
+	return s.fitAlert(lang, alertTemplate, alertData{
+		AlertDetails: alert,
+		Published:    formatAlertTime(lang, alert, s.clock()),
+		Category:     i18n.Translate(lang, strings.ToLower(category)),
+		IsState:      isState,
+	})
+}
+
+// fitAlert renders the alert template called name in lang with data,
+// shortening the alert's description, and leaving out its URL when there is
+// no room for it, so the text fits the segment budget.
+func (s *Server) fitAlert(lang, name string, data alertData) (string, error) {
+	url := data.URL
+	return sms.Fit(s.maxSegments, data.AlertMessage, func(text string, withLink bool) (string, error) {
+		data.AlertMessage = text
+		data.URL = ""
+		if withLink {
+			data.URL = url
+		}
+		return s.replies().render(lang, name, data)
+	})
+}
+
+// formatAlertsLabel names the alerts in category in lang, e.g. "park closure
+// alerts", or just "alerts" when there is no category.
+func formatAlertsLabel(lang, category string) string {
+	if category == "" {
+		return i18n.Translate(lang, alertsLabel)
+	}
+	return i18n.Sprintf(lang, categoryAlertsLabel, i18n.Translate(lang, strings.ToLower(category)))
+}
+
+// formatNewAlert renders the text the poller sends a subscriber about a new
+// alert, in the subscriber's language.
+func (s *Server) formatNewAlert(ctx context.Context, sub store.Subscription, alert nps.AlertDetails) (string, error) {
+	lang, err := s.savedLanguage(ctx, sub.Phone)
+	if err != nil {
+		// the alert is still worth sending in English
+		logging.FromContext(ctx).Warn("failed to load language", zap.Error(err))
+	}
+
+	return s.fitAlert(lang, newAlertTemplate, alertData{
+		AlertDetails: alert,
+		Published:    formatAlertTime(lang, alert, s.clock()),
+		Subscription: sub,
+	})
+}
+
+// formatStatesAlerts renders the newest alert of each state, in the order
+// the states were asked for, as one text in lang. alerts must be newest
+// first. Headlines are shortened to fit the segment budget.
+func (s *Server) formatStatesAlerts(lang string, states []string, alerts []nps.AlertDetails, label string) string {
+	newest := map[string]nps.AlertDetails{}
+	for _, alert := range alerts {
+		if _, ok := newest[alert.StateCode]; !ok {
+			newest[alert.StateCode] = alert
+		}
+	}
+
+	headlines := make([]string, len(states))
+	for i, code := range states {
+		headlines[i] = newest[code].AlertHeader
+	}
+
+	names := stateNames(states)
+	return s.fitHeadlines(headlines, func(headlines []string) string {
+		sections := make([]string, 0, len(states))
+		for i, code := range states {
+			alert, ok := newest[code]
+			if !ok {
+				sections = append(sections, i18n.Sprintf(lang, stateNoAlerts, names[i], label))
+				continue
+			}
+			sections = append(sections, i18n.Sprintf(lang, stateAlertSection,
+				names[i],
+				alert.FullParkName,
+				formatAlertTime(lang, alert, s.clock()),
+				headlines[i],
+				alert.URL))
+		}
+
+		return i18n.Sprintf(lang, statesAlertMessage, label, joinList(names, i18n.Translate(lang, "and")), strings.Join(sections, "\n\n"))
+	})
+}
+
+// fitHeadlines renders a text listing headlines with render, shortening
+// them all to the same length, no more than needed, so the text fits the
+// segment budget. render is given the headlines transliterated and possibly
+// shortened.
+func (s *Server) fitHeadlines(headlines []string, render func(headlines []string) string) string {
+	longest := 0
+	for _, h := range headlines {
+		if n := len([]rune(sms.Transliterate(h))); n > longest {
+			longest = n
+		}
+	}
+
+	try := func(n int) (string, bool) {
+		shortened := make([]string, len(headlines))
+		for i, h := range headlines {
+			shortened[i] = sms.Shorten(sms.Transliterate(h), n)
+		}
+		text := sms.Transliterate(render(shortened))
+		return text, s.maxSegments <= 0 || sms.Measure(text).Segments <= s.maxSegments
+	}
+
+	if text, ok := try(longest); ok {
+		return text
+	}
+
+	lo, hi := 0, longest
+	for lo < hi {
+		n := (lo + hi + 1) / 2
+		if _, ok := try(n); ok {
+			lo = n
+		} else {
+			hi = n - 1
+		}
+	}
+
+	text, _ := try(lo)
+	return text
+}
+
+// stateNames returns the full name of each state code, or the code itself
+// when it is not known.
+func stateNames(codes []string) []string {
+	names := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name, ok := nps.StateName(code)
+		if !ok {
+			name = code
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// joinList phrases items as a list, e.g. "Utah, Nevada and Arizona".
+func joinList(items []string, conjunction string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conjunction + " " + items[len(items)-1]
+}
+
+// formatAlertTime renders in lang when an alert was published in the local
+// time of the alert's state along with how long ago that was, e.g.
+// "Jun 7 at 10:55 AM PDT (3 hours ago)". It falls back to the raw NPS date
+// when that could not be parsed.
+func formatAlertTime(lang string, alert nps.AlertDetails, now time.Time) string {
+	if alert.RecentAlertTime.IsZero() {
+		return alert.RecentAlertDate
+	}
+
+	loc, ok := nps.StateLocation(alert.StateCode)
+	if !ok {
+		loc = time.UTC
+	}
+
+	return fmt.Sprintf("%s (%s)",
+		i18n.FormatTime(lang, alert.RecentAlertTime.In(loc)),
+		i18n.Ago(lang, now.Sub(alert.RecentAlertTime)))
+}
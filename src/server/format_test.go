@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/sms"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAlertTime(t *testing.T) {
+	assert := assert.New(t)
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+
+	alert := nps.AlertDetails{
+		RecentAlertDate: "2022-06-07 13:55:48.0",
+		RecentAlertTime: published,
+		StateCode:       "CA",
+	}
+
+	formatted := formatAlertTime(i18n.English, alert, published.Add(3*time.Hour+10*time.Minute))
+
+	assert.Equal("Jun 7 at 10:55 AM PDT (3 hours ago)", formatted)
+}
+
+func TestFormatAlertTimeUnknownState(t *testing.T) {
+	assert := assert.New(t)
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+
+	alert := nps.AlertDetails{
+		RecentAlertTime: published,
+	}
+
+	assert.Equal("Jun 7 at 5:55 PM UTC (just now)", formatAlertTime(i18n.English, alert, published))
+}
+
+func TestFormatAlertTimeUnparsed(t *testing.T) {
+	assert := assert.New(t)
+
+	alert := nps.AlertDetails{
+		RecentAlertDate: "TEST_DATE",
+		StateCode:       "CA",
+	}
+
+	assert.Equal("TEST_DATE", formatAlertTime(i18n.English, alert, time.Now()))
+}
+
+func TestFormatNewAlert(t *testing.T) {
+	assert := assert.New(t)
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+
+	s := &Server{now: func() time.Time { return published.Add(time.Hour) }}
+
+	message, err := s.formatNewAlert(context.Background(),
+		store.Subscription{
+			Phone: "+12407439754",
+			Topic: store.Topic{Kind: store.TopicPark, Code: "yose"},
+			Name:  "Yosemite",
+		},
+		nps.AlertDetails{
+			FullParkName:    "Yosemite",
+			RecentAlertTime: published,
+			StateCode:       "CA",
+			AlertHeader:     "Tioga Road is closed",
+			AlertMessage:    "Tioga Road is closed for the season.",
+			URL:             "https://www.nps.gov/yose/planyourvisit/conditions.htm",
+		})
+
+	assert.Nil(err)
+	assert.Equal("New NPS alert for Yosemite from Yosemite, published Jun 7 at 10:55 AM PDT (1 hour ago):\n\nTioga Road is closed\n\nTioga Road is closed for the season.\n\nFor a full list of alerts, visit https://www.nps.gov/yose/planyourvisit/conditions.htm\n\nText \"unsubscribe yose\" to stop these texts.", message)
+}
+
+func TestFormatNewAlertSegmentBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+
+	s := &Server{
+		now:         func() time.Time { return published.Add(time.Hour) },
+		maxSegments: 2,
+	}
+
+	message, err := s.formatNewAlert(context.Background(),
+		store.Subscription{
+			Phone: "+12407439754",
+			Topic: store.Topic{Kind: store.TopicPark, Code: "yose"},
+			Name:  "Yosemite",
+		},
+		nps.AlertDetails{
+			FullParkName:    "Yosemite",
+			RecentAlertTime: published,
+			StateCode:       "CA",
+			AlertHeader:     "Tioga Road is closed",
+			AlertMessage:    strings.Repeat("Tioga Road is closed for the season. ", 20),
+			URL:             "https://www.nps.gov/yose/planyourvisit/conditions.htm",
+		})
+
+	assert.Nil(err)
+	assert.LessOrEqual(sms.Measure(message).Segments, 2)
+	assert.True(strings.HasPrefix(message, "New NPS alert for Yosemite from Yosemite"))
+	assert.Contains(message, "...")
+	assert.True(strings.HasSuffix(message, "Text \"unsubscribe yose\" to stop these texts."))
+}
+
+func TestFormatNewAlertSpanish(t *testing.T) {
+	assert := assert.New(t)
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+
+	st := store.NewMemory()
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{Language: i18n.Spanish})
+
+	s := &Server{
+		now:         func() time.Time { return published.Add(time.Hour) },
+		preferences: st,
+	}
+
+	message, err := s.formatNewAlert(context.Background(),
+		store.Subscription{
+			Phone: "+12407439754",
+			Topic: store.Topic{Kind: store.TopicPark, Code: "yose"},
+			Name:  "Yosemite",
+		},
+		nps.AlertDetails{
+			FullParkName:    "Yosemite",
+			RecentAlertTime: published,
+			StateCode:       "CA",
+			AlertHeader:     "Tioga Road is closed",
+			AlertMessage:    "Tioga Road is closed for the season.",
+		})
+
+	assert.Nil(err)
+	assert.Equal("Nueva alerta del NPS para Yosemite de Yosemite, publicada el 7 de junio a las 10:55 PDT (hace 1 hora):\n\nTioga Road is closed\n\nTioga Road is closed for the season.\n\nEnvia \"desuscribir yose\" para dejar de recibir estos textos.", message)
+}
+
+func TestFormatAlertTimeSpanish(t *testing.T) {
+	assert := assert.New(t)
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+	alert := nps.AlertDetails{RecentAlertTime: published, StateCode: "UT"}
+
+	assert.Equal("7 de junio a las 11:55 MDT (hace 2 días)", formatAlertTime(i18n.Spanish, alert, published.Add(50*time.Hour)))
+}
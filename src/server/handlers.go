@@ -1,19 +1,24 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/sms"
+	"github.com/WilliamDeBruin/nps_alerts/src/twilio"
+	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
 )
 
 const (
-	helpPrefix  = "help"
-	alertPrefix = "alerts "
-
-	helpMessage  = "Welcome to NPS alerts! Here is a list of commands:\n\nHelp: receive this help text\n\nAlerts {state}: Text \"alerts\" followed by the 2-letter state code of the state you would like to see alerts for"
-	alertMessage = "Here is the most recent NPS %s alert from %s, published %s:\n\n%s\n\n%s\n\nFor a full list of NPS %s alerts, visit %s"
+	noAlertsMessage = "There are no current NPS %s for %s."
 )
 
 func (s *Server) HealthHandler(w http.ResponseWriter, r *http.Request) {
@@ -21,93 +26,276 @@ func (s *Server) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// requestContext derives the context for the work behind an inbound request.
+// It is cancelled when the client goes away or the server shuts down, bounded
+// by the configured request timeout, and carries a logger tagged with the
+// request ID set by chi's RequestID middleware.
+func (s *Server) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := r.Context()
+
+	logger := s.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		logger = logger.With(zap.String("requestID", reqID))
+	}
+	ctx = logging.WithLogger(ctx, logger)
+
+	if s.requestTimeout > 0 {
+		return context.WithTimeout(ctx, s.requestTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// lookupContext bounds an NPS lookup by the lookup timeout, which is shorter
+// than the request's, so there is time left to reply when NPS is slow.
+func (s *Server) lookupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.lookupTimeout > 0 {
+		return context.WithTimeout(ctx, s.lookupTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// replyContext derives the context for texting the reply to an inbound
+// request. The request's own context may be used up by a slow lookup, so the
+// reply gets a budget of its own, but it still stops when the server shuts
+// down and logs with the request's logger.
+func (s *Server) replyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	base := s.ctx
+	if base == nil {
+		base = context.Background()
+	}
+	base = logging.WithLogger(base, logging.FromContext(ctx))
+
+	if s.replyTimeout > 0 {
+		return context.WithTimeout(base, s.replyTimeout)
+	}
+	return context.WithCancel(base)
+}
+
 func (s *Server) IncomingSmsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	r = r.WithContext(ctx)
+	logger := logging.FromContext(ctx)
 
-	headerContentType := r.Header.Get("Content-Type")
-	if headerContentType != "application/x-www-form-urlencoded" {
+	// Twilio may add a charset parameter to the content type
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
 		w.WriteHeader(http.StatusUnsupportedMediaType)
 		return
 	}
 
-	from := r.FormValue("from")
-	if from == "" {
-		s.logger.Error("missing field in request body: from")
+	msg, err := parseInboundMessage(r)
+	if err != nil {
+		logger.Error(err.Error())
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
-	body := r.FormValue("body")
-	if body == "" {
-		s.logger.Error("missing field in request body: body")
-		w.WriteHeader(http.StatusBadRequest)
+	logger = logger.With(zap.String("messageSid", msg.MessageSID))
+	ctx = logging.WithLogger(withInboundMessage(ctx, msg), logger)
+	r = r.WithContext(ctx)
+
+	name, args := parseCommand(msg.Body)
+	if name == "" && msg.NumMedia > 0 {
+		// there is no command in a picture, so say what can be texted
+		name = "help"
 	}
 
-	if strings.HasPrefix(body, helpPrefix) {
-		s.helpHandler(w, r)
-		return
-	} else if strings.HasPrefix(body, alertPrefix) {
-		s.alertHandler(w, r)
-		return
-	} else {
-		s.logger.Error("unhandled text body")
+	cmd, ok := route(name, args)
+	if !ok {
+		logger.Error("unhandled text body")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+
+	// a command word in another language, like "alertas", is answered in
+	// that language, anything else in the sender's chosen one
+	lang := cmd.language(name)
+	if lang == "" {
+		lang, err = s.savedLanguage(ctx, msg.From)
+		if err != nil {
+			logger.Warn("failed to load language", zap.Error(err))
+		}
+	}
+	r = r.WithContext(withLanguage(ctx, lang))
+
+	cmd.handler(s, w, r, args)
 }
 
-func (s *Server) helpHandler(w http.ResponseWriter, r *http.Request) {
-	err := s.twilioClient.SendMessage(r.FormValue("from"), helpMessage)
+func (s *Server) helpHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	from := inboundMessageFromContext(ctx).From
+
+	lang := languageFromContext(ctx)
+	message, err := s.replies().render(lang, helpTemplate, helpFor(commands, lang))
 	if err != nil {
-		s.logger.Error(err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		logger.Error("failed to render help", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
 		return
 	}
-	s.logger.Info("sent help message")
-	w.WriteHeader(http.StatusOK)
+
+	if s.reply(w, r, from, message, http.StatusOK) {
+		logger.Info("sent help message")
+	}
 }
 
-func (s *Server) alertHandler(w http.ResponseWriter, r *http.Request) {
-	body := r.FormValue("body")
-	body = strings.TrimSpace(body)
-	from := r.FormValue("from")
-	words := strings.Split(body, " ")
+func (s *Server) alertHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	msg := inboundMessageFromContext(ctx)
+	from := msg.From
+	lang := languageFromContext(ctx)
+
+	// enough alerts for the texter to page through with "more"
+	opts := &nps.AlertOptions{MaxResults: maxSessionAlerts}
+
+	// a trailing category word narrows the results, e.g. "alerts CA closures"
+	category := ""
+	if len(args) > 0 {
+		if c, ok := parseCategory(args[len(args)-1]); ok {
+			category = c
+			opts.Categories = []string{category}
+			args = args[:len(args)-1]
+		}
+	}
+	label := formatAlertsLabel(lang, category)
 
-	if len(words) != 2 {
-		err := s.twilioClient.SendMessage(from, `I'm sorry, I couldn't understand your message. Please text "alerts {state}" for recent alerts`)
+	// with no state or park, answer for the sender's home state
+	if len(args) == 0 {
+		state, err := s.defaultState(ctx, msg)
 		if err != nil {
-			s.logger.Error(err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+			logger.Error("failed to load preferences", zap.Error(err))
+			s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
 			return
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		if state == "" {
+			s.reply(w, r, from, tr(ctx, unknownHomeMessage), http.StatusBadRequest)
+			return
+		}
+		args = []string{state}
 	}
 
-	stateCode := words[1]
+	// states can be given as codes or names, like "alerts CA NV" or "alerts
+	// new mexico", anything else is a park code or name
+	target := strings.Join(args, " ")
+	states, isState := nps.ParseStates(args)
+	if !isState && len(args) == 1 && len(target) == 2 {
+		// let NPS reject it so the reply says it isn't a state code
+		states, isState = []string{strings.ToUpper(target)}, true
+	}
 
-	alert, err := s.npsClient.GetAlert(stateCode)
+	var alerts []nps.AlertDetails
+	var err error
+
+	lookupCtx, cancel := s.lookupContext(ctx)
+	if isState {
+		target = strings.Join(states, ",")
+		if len(states) > 1 {
+			// one query for every state, then the newest alert of each
+			opts.MaxResults = 0
+		}
+		alerts, err = s.npsClient.GetAlerts(lookupCtx, target, opts)
+	} else {
+		alerts, err = s.npsClient.GetParkAlerts(lookupCtx, target, opts)
+	}
+	cancel()
 
 	if err != nil {
-		s.logger.Error(err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		message, status := npsErrorReply(lang, err, target)
+		if s.reply(w, r, from, message, status) {
+			if status >= http.StatusInternalServerError {
+				logger.Error(err.Error())
+			} else {
+				logger.Info(err.Error())
+			}
+		} else {
+			logger.Error(err.Error())
+		}
+		return
+	}
+
+	if len(alerts) == 0 {
+		logger.Info("no alerts found", zap.String("target", target))
+		if len(states) > 1 {
+			target = joinList(stateNames(states), tr(ctx, "and"))
+		}
+		s.reply(w, r, from, tr(ctx, noAlertsMessage, label, target), http.StatusOK)
+		return
+	}
+
+	if len(states) > 1 {
+		logger.Info("alerts response", zap.Strings("states", states), zap.Int("alerts", len(alerts)))
+		s.reply(w, r, from, s.formatStatesAlerts(lang, states, alerts, label), http.StatusOK)
 		return
 	}
 
-	s.logger.Info("alert response", zap.Any("alertResponse", alert))
+	session := newSession(states, isState, category, alerts)
 
-	message := fmt.Sprintf(alertMessage,
-		alert.FullStateName,
-		alert.FullParkName,
-		alert.RecentAlertDate,
-		alert.AlertHeader,
-		alert.AlertMessage,
-		alert.FullStateName,
-		alert.URL)
+	var message string
+	if len(alerts) == 1 {
+		logger.Info("alert response", zap.Any("alertResponse", alerts[0]))
+		message, err = s.formatAlert(lang, alerts[0], isState, category)
+		if err != nil {
+			logger.Error("failed to render alert", zap.Error(err))
+			s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+			return
+		}
+		session.Next = 1
+	} else {
+		logger.Info("alerts list response", zap.String("target", target), zap.Int("alerts", len(alerts)))
+		message = s.formatAlertsPage(lang, &session)
+	}
+
+	// the list is still worth sending when it cannot be saved, only "more"
+	// and "read" will not find it
+	if err := s.saveSession(ctx, from, session); err != nil {
+		logger.Error("failed to save session", zap.Error(err))
+	}
+
+	s.reply(w, r, from, message, http.StatusOK)
+}
 
-	err = s.twilioClient.SendMessage(from, message)
+// clock returns the current time, which tests can pin with the now field.
+func (s *Server) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// reply texts message back to the sender and writes status. When the message
+// cannot be sent it logs the error, writes a 500 and returns false. Senders
+// who have opted out get no reply, but the request still succeeds.
+func (s *Server) reply(w http.ResponseWriter, r *http.Request, to, message string, status int) bool {
+	ctx, cancel := s.replyContext(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
 
+	// a single curly quote would send the whole text as UCS-2
+	message = sms.Transliterate(message)
+
+	if s.twimlReplies {
+		return s.replyTwiML(w, r, to, message)
+	}
+
+	err := s.twilioClient.SendMessage(ctx, to, message)
+	if errors.Is(err, twilio.ErrOptedOut) {
+		// nothing can be sent until the texter opts back in with START
+		logging.FromContext(ctx).Info("not replying to opted out number")
+		w.WriteHeader(status)
+		return false
+	}
 	if err != nil {
-		s.logger.Error(err.Error())
+		logging.FromContext(ctx).Error(err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return false
 	}
+
+	w.WriteHeader(status)
+	return true
 }
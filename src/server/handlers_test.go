@@ -1,36 +1,79 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/sms"
+	"github.com/go-chi/chi/middleware"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest/observer"
 )
 
 type mockNpsClient struct {
-	getAlertResponse *nps.AlertDetails
-	getAlertErr      error
+	getAlertResponse  *nps.AlertDetails
+	getAlertErr       error
+	getAlertsResponse []nps.AlertDetails
+	getAlertsErr      error
+	getParkAlertsErr  error
+	lastStateCode     string
+	lastPark          string
+	lastOpts          *nps.AlertOptions
+	lastCtx           context.Context
+
+	// slow makes lookups wait until their context is done, like an NPS
+	// that does not answer.
+	slow bool
 }
 
-func (m *mockNpsClient) GetAlert(stateCode string) (*nps.AlertDetails, error) {
+func (m *mockNpsClient) GetAlert(ctx context.Context, stateCode string) (*nps.AlertDetails, error) {
 	return m.getAlertResponse, m.getAlertErr
 }
 
+func (m *mockNpsClient) GetAlerts(ctx context.Context, stateCode string, opts *nps.AlertOptions) ([]nps.AlertDetails, error) {
+	m.lastCtx = ctx
+	m.lastStateCode = stateCode
+	m.lastOpts = opts
+	if m.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return m.getAlertsResponse, m.getAlertsErr
+}
+
+func (m *mockNpsClient) GetParkAlerts(ctx context.Context, park string, opts *nps.AlertOptions) ([]nps.AlertDetails, error) {
+	m.lastCtx = ctx
+	m.lastPark = park
+	m.lastOpts = opts
+	if m.getParkAlertsErr != nil {
+		return nil, m.getParkAlertsErr
+	}
+	return m.getAlertsResponse, m.getAlertsErr
+}
+
 func (m *mockNpsClient) SetTransport(rt http.RoundTripper) {}
 
 type mockTwilioClient struct {
 	sendMessageErr error
+	lastMessage    string
 }
 
-func (m *mockTwilioClient) SendMessage(to, message string) error {
+func (m *mockTwilioClient) SendMessage(ctx context.Context, to, message string) error {
+	// like the real client, nothing is sent on a context that is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lastMessage = message
 	return m.sendMessageErr
 }
 
@@ -119,13 +162,15 @@ func TestIncomingSmsAlert(t *testing.T) {
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	mockNpsClient := &mockNpsClient{}
-	mockNpsClient.getAlertResponse = &nps.AlertDetails{
-		FullStateName:   "TEST_FULL_STATE_NAME",
-		FullParkName:    "TEST_FULL_PARK_NAME",
-		RecentAlertDate: "TEST_DATE",
-		AlertHeader:     "TEST_HEADER",
-		AlertMessage:    "TEST_MESSAGE",
-		URL:             "TEST_URL",
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
 	}
 
 	mockTwilioClient := &mockTwilioClient{}
@@ -146,6 +191,83 @@ func TestIncomingSmsAlert(t *testing.T) {
 	assert.Equal(w.Result().StatusCode, http.StatusOK)
 }
 
+func TestIncomingSmsAlertLocalTime(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts CA")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	published := time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC)
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "California",
+			FullParkName:    "Alcatraz Island",
+			RecentAlertDate: "2022-06-07 13:55:48.0",
+			RecentAlertTime: published,
+			StateCode:       "CA",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
+	}
+
+	mockTwilioClient := &mockTwilioClient{}
+
+	core, _ := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:    mockNpsClient,
+		twilioClient: mockTwilioClient,
+		logger:       logger,
+		now:          func() time.Time { return published.Add(3 * time.Hour) },
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(mockTwilioClient.lastMessage, "Here is the most recent NPS California alert from Alcatraz Island, published Jun 7 at 10:55 AM PDT (3 hours ago):\n\nTEST_HEADER\n\nTEST_MESSAGE\n\nFor a full list of NPS California alerts, visit TEST_URL")
+}
+
+func TestIncomingSmsAlertRequestContext(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts CA")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	r = r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, "TEST_REQUEST_ID"))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{{AlertHeader: "TEST_HEADER"}}
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:      mockNpsClient,
+		twilioClient:   &mockTwilioClient{},
+		logger:         logger,
+		requestTimeout: time.Minute,
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	_, hasDeadline := mockNpsClient.lastCtx.Deadline()
+	assert.True(hasDeadline)
+	assert.Equal(logs.All()[0].Message, "alert response")
+	assert.Equal(logs.All()[0].ContextMap()["requestID"], "TEST_REQUEST_ID")
+}
+
 func TestIncomingSmsAlertSendMessageFail(t *testing.T) {
 	assert := assert.New(t)
 
@@ -158,13 +280,15 @@ func TestIncomingSmsAlertSendMessageFail(t *testing.T) {
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	mockNpsClient := &mockNpsClient{}
-	mockNpsClient.getAlertResponse = &nps.AlertDetails{
-		FullStateName:   "TEST_FULL_STATE_NAME",
-		FullParkName:    "TEST_FULL_PARK_NAME",
-		RecentAlertDate: "TEST_DATE",
-		AlertHeader:     "TEST_HEADER",
-		AlertMessage:    "TEST_MESSAGE",
-		URL:             "TEST_URL",
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
 	}
 
 	mockTwilioClient := &mockTwilioClient{}
@@ -197,15 +321,17 @@ func TestIncomingSmsAlertGetAlertFail(t *testing.T) {
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	mockNpsClient := &mockNpsClient{}
-	mockNpsClient.getAlertResponse = &nps.AlertDetails{
-		FullStateName:   "TEST_FULL_STATE_NAME",
-		FullParkName:    "TEST_FULL_PARK_NAME",
-		RecentAlertDate: "TEST_DATE",
-		AlertHeader:     "TEST_HEADER",
-		AlertMessage:    "TEST_MESSAGE",
-		URL:             "TEST_URL",
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
 	}
-	mockNpsClient.getAlertErr = errors.New("TEST_FAILED_TO_GET_ALERT")
+	mockNpsClient.getAlertsErr = errors.New("TEST_FAILED_TO_GET_ALERT")
 
 	mockTwilioClient := &mockTwilioClient{}
 	mockTwilioClient.sendMessageErr = nil
@@ -225,11 +351,182 @@ func TestIncomingSmsAlertGetAlertFail(t *testing.T) {
 	assert.Equal(w.Result().StatusCode, http.StatusInternalServerError)
 }
 
+func TestIncomingSmsAlertNoAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts CA")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{}
+
+	mockTwilioClient := &mockTwilioClient{}
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:    mockNpsClient,
+		twilioClient: mockTwilioClient,
+		logger:       logger,
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(logs.All()[0].Message, "no alerts found")
+	assert.Equal(mockTwilioClient.lastMessage, "There are no current NPS alerts for CA.")
+	assert.Equal(w.Result().StatusCode, http.StatusOK)
+}
+
+func TestIncomingSmsAlertCategory(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts ca closures")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{}
+
+	mockTwilioClient := &mockTwilioClient{}
+
+	core, _ := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:    mockNpsClient,
+		twilioClient: mockTwilioClient,
+		logger:       logger,
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(mockNpsClient.lastStateCode, "CA")
+	assert.Equal(mockNpsClient.lastOpts.Categories, []string{nps.CategoryClosure})
+	assert.Equal(mockTwilioClient.lastMessage, "There are no current NPS park closure alerts for CA.")
+	assert.Equal(w.Result().StatusCode, http.StatusOK)
+}
+
+func TestIncomingSmsParkAlert(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts grand canyon")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
+	}
+
+	mockTwilioClient := &mockTwilioClient{}
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:    mockNpsClient,
+		twilioClient: mockTwilioClient,
+		logger:       logger,
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(mockNpsClient.lastPark, "grand canyon")
+	assert.Equal(logs.All()[0].Message, "alert response")
+	assert.Equal(mockTwilioClient.lastMessage, "Here is the most recent NPS alert from TEST_FULL_PARK_NAME, published TEST_DATE:\n\nTEST_HEADER\n\nTEST_MESSAGE\n\nFor a full list of TEST_FULL_PARK_NAME alerts, visit TEST_URL")
+	assert.Equal(w.Result().StatusCode, http.StatusOK)
+}
+
+func TestIncomingSmsAlertNpsUnavailable(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts CA")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsErr = fmt.Errorf("circuit breaker open: %w", nps.ErrUnavailable)
+
+	mockTwilioClient := &mockTwilioClient{}
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:    mockNpsClient,
+		twilioClient: mockTwilioClient,
+		logger:       logger,
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(logs.All()[0].Message, "circuit breaker open: nps api is unavailable")
+	assert.Equal(mockTwilioClient.lastMessage, "NPS is unavailable right now, please try again later.")
+	assert.Equal(w.Result().StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestIncomingSmsAlertInvalidState(t *testing.T) {
+	assert := assert.New(t)
+
+	data := url.Values{}
+	data.Set("body", "alerts MV")
+	data.Set("from", "+12407439754")
+
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mockNpsClient := &mockNpsClient{}
+	mockNpsClient.getAlertsErr = fmt.Errorf("state code MV is not a valid state code: %w", nps.ErrInvalidState)
+
+	mockTwilioClient := &mockTwilioClient{}
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	s := Server{
+		npsClient:    mockNpsClient,
+		twilioClient: mockTwilioClient,
+		logger:       logger,
+	}
+
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(logs.All()[0].Level, zap.InfoLevel)
+	assert.Equal(mockTwilioClient.lastMessage, `I'm sorry, "MV" isn't a state code I recognize. Please text "alerts" followed by a 2-letter state code, like "alerts UT"`)
+	assert.Equal(w.Result().StatusCode, http.StatusBadRequest)
+}
+
 func TestIncomingSmsAlertBadMessage(t *testing.T) {
 	assert := assert.New(t)
 
 	data := url.Values{}
-	data.Set("body", "alerts new mexico")
+	data.Set("body", "alerts nowhere special")
 	data.Set("from", "+12407439754")
 
 	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
@@ -237,15 +534,19 @@ func TestIncomingSmsAlertBadMessage(t *testing.T) {
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	mockNpsClient := &mockNpsClient{}
-	mockNpsClient.getAlertResponse = &nps.AlertDetails{
-		FullStateName:   "TEST_FULL_STATE_NAME",
-		FullParkName:    "TEST_FULL_PARK_NAME",
-		RecentAlertDate: "TEST_DATE",
-		AlertHeader:     "TEST_HEADER",
-		AlertMessage:    "TEST_MESSAGE",
-		URL:             "TEST_URL",
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
 	}
 
+	mockNpsClient.getParkAlertsErr = nps.ErrUnknownPark
+
 	mockTwilioClient := &mockTwilioClient{}
 	mockTwilioClient.sendMessageErr = nil
 
@@ -267,7 +568,7 @@ func TestIncomingSmsAlertBadMessageSendFail(t *testing.T) {
 	assert := assert.New(t)
 
 	data := url.Values{}
-	data.Set("body", "alerts new mexico")
+	data.Set("body", "alerts nowhere special")
 	data.Set("from", "+12407439754")
 
 	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
@@ -275,15 +576,19 @@ func TestIncomingSmsAlertBadMessageSendFail(t *testing.T) {
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	mockNpsClient := &mockNpsClient{}
-	mockNpsClient.getAlertResponse = &nps.AlertDetails{
-		FullStateName:   "TEST_FULL_STATE_NAME",
-		FullParkName:    "TEST_FULL_PARK_NAME",
-		RecentAlertDate: "TEST_DATE",
-		AlertHeader:     "TEST_HEADER",
-		AlertMessage:    "TEST_MESSAGE",
-		URL:             "TEST_URL",
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
 	}
 
+	mockNpsClient.getParkAlertsErr = nps.ErrUnknownPark
+
 	mockTwilioClient := &mockTwilioClient{}
 	mockTwilioClient.sendMessageErr = errors.New("TEST_SEND_MESSAGE_FAIL_ERR")
 
@@ -314,13 +619,15 @@ func TestIncomingSmsUnhandledBody(t *testing.T) {
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	mockNpsClient := &mockNpsClient{}
-	mockNpsClient.getAlertResponse = &nps.AlertDetails{
-		FullStateName:   "TEST_FULL_STATE_NAME",
-		FullParkName:    "TEST_FULL_PARK_NAME",
-		RecentAlertDate: "TEST_DATE",
-		AlertHeader:     "TEST_HEADER",
-		AlertMessage:    "TEST_MESSAGE",
-		URL:             "TEST_URL",
+	mockNpsClient.getAlertsResponse = []nps.AlertDetails{
+		{
+			FullStateName:   "TEST_FULL_STATE_NAME",
+			FullParkName:    "TEST_FULL_PARK_NAME",
+			RecentAlertDate: "TEST_DATE",
+			AlertHeader:     "TEST_HEADER",
+			AlertMessage:    "TEST_MESSAGE",
+			URL:             "TEST_URL",
+		},
 	}
 
 	mockTwilioClient := &mockTwilioClient{}
@@ -408,3 +715,179 @@ func TestIncomingSmsMissingBody(t *testing.T) {
 	assert.Equal(w.Result().StatusCode, http.StatusBadRequest)
 	assert.Equal(logs.All()[0].Message, "missing field in request body: body")
 }
+
+func TestIncomingSmsAlertStateName(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{StateCode: "NM", FullStateName: "New Mexico"}}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts New Mexico"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("NM", npsClient.lastStateCode)
+	assert.Equal(maxSessionAlerts, npsClient.lastOpts.MaxResults)
+	assert.Empty(npsClient.lastPark)
+}
+
+func TestIncomingSmsAlertMultipleStates(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	published := time.Date(2022, 8, 2, 10, 0, 0, 0, time.UTC)
+	npsClient.getAlertsResponse = []nps.AlertDetails{
+		{StateCode: "AZ", FullParkName: "Grand Canyon", AlertHeader: "AZ_NEWEST", URL: "AZ_URL", RecentAlertTime: published},
+		{StateCode: "CA", FullParkName: "Yosemite", AlertHeader: "CA_HEADER", URL: "CA_URL", RecentAlertTime: published.Add(-time.Hour)},
+		{StateCode: "AZ", FullParkName: "Saguaro", AlertHeader: "AZ_OLDER", URL: "AZ_URL", RecentAlertTime: published.Add(-2 * time.Hour)},
+	}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA, nevada and AZ"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("CA,NV,AZ", npsClient.lastStateCode)
+	assert.Equal(0, npsClient.lastOpts.MaxResults)
+	assert.Equal("Here are the most recent NPS alerts for California, Nevada and Arizona:\n\n"+
+		"California, from Yosemite, published Aug 2 at 2:00 AM PDT (3 hours ago):\nCA_HEADER\nCA_URL\n\n"+
+		"Nevada: no current alerts\n\n"+
+		"Arizona, from Grand Canyon, published Aug 2 at 3:00 AM MST (2 hours ago):\nAZ_NEWEST\nAZ_URL",
+		twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertNpsTooSlow(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	s.requestTimeout = 20 * time.Millisecond
+	npsClient.slow = true
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(unavailableMessage, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertNpsTooSlowForLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	s.lookupTimeout = 20 * time.Millisecond
+	npsClient.slow = true
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusServiceUnavailable, w.Result().StatusCode)
+	assert.Equal(unavailableMessage, twilioClient.lastMessage)
+}
+
+func TestReplyStopsOnShutdown(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.cancel()
+
+	w := httptest.NewRecorder()
+	sent := s.reply(w, smsRequest("help"), "+12407439754", "hello", http.StatusOK)
+
+	assert.False(sent)
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+	assert.Empty(twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertMultipleStatesSegmentBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	s.maxSegments = 2
+	headline := strings.Repeat("Road closed for construction ", 10)
+	npsClient.getAlertsResponse = []nps.AlertDetails{
+		{StateCode: "AZ", FullParkName: "Grand Canyon", AlertHeader: headline, URL: "AZ_URL"},
+		{StateCode: "CA", FullParkName: "Yosemite", AlertHeader: headline, URL: "CA_URL"},
+	}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA AZ"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.LessOrEqual(sms.Measure(twilioClient.lastMessage).Segments, 2)
+	assert.Contains(twilioClient.lastMessage, "Road closed for construction")
+	assert.Contains(twilioClient.lastMessage, "...\nCA_URL")
+	assert.True(strings.HasSuffix(twilioClient.lastMessage, "...\nAZ_URL"))
+}
+
+func TestIncomingSmsAlertMultipleStatesNoAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts utah nevada closures"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("There are no current NPS park closure alerts for Utah and Nevada.", twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertSegmentBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	s.maxSegments = 2
+	npsClient.getAlertsResponse = []nps.AlertDetails{{
+		StateCode:       "CA",
+		FullStateName:   "California",
+		FullParkName:    "Yosemite",
+		RecentAlertDate: "TEST_DATE",
+		AlertHeader:     "Tioga Road is closed",
+		AlertMessage:    strings.Repeat("Tioga Road is closed for the season. ", 20),
+		URL:             "https://www.nps.gov/yose/planyourvisit/conditions.htm",
+	}}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.LessOrEqual(sms.Measure(twilioClient.lastMessage).Segments, 2)
+	assert.True(strings.HasPrefix(twilioClient.lastMessage, "Here is the most recent NPS California alert from Yosemite, published TEST_DATE:\n\nTioga Road is closed\n\nTioga Road"))
+	assert.Contains(twilioClient.lastMessage, "...")
+}
+
+func TestIncomingSmsAlertTransliterated(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{
+		StateCode:       "CA",
+		FullStateName:   "California",
+		FullParkName:    "Yosemite",
+		RecentAlertDate: "TEST_DATE",
+		AlertHeader:     "Tioga Road – closed",
+		AlertMessage:    "It’s closed for the season.",
+		URL:             "TEST_URL",
+	}}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Contains(twilioClient.lastMessage, "Tioga Road - closed\n\nIt's closed for the season.")
+	assert.True(sms.IsGSM(twilioClient.lastMessage))
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/WilliamDeBruin/nps_alerts/src/twilio"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// serverOption changes the Server built by newTestServer.
+type serverOption func(*Server)
+
+// newTestServer returns a Server for handler tests with the embedded park
+// catalog, a fixed clock, mock NPS and Twilio clients, and a memory store for
+// subscriptions, preferences and sessions. opts swap in what a test needs to
+// look at or change.
+func newTestServer(t *testing.T, opts ...serverOption) *Server {
+	parks, err := nps.NewDirectory("TEST_KEY", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := store.NewMemory()
+
+	s := &Server{
+		npsClient:     &mockNpsClient{},
+		twilioClient:  &mockTwilioClient{},
+		parks:         parks,
+		subscriptions: st,
+		preferences:   st,
+		sessions:      st,
+		logger:        zap.NewNop(),
+		now:           func() time.Time { return time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC) },
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// withStore keeps subscriptions, preferences and sessions in st.
+func withStore(st *store.Memory) serverOption {
+	return func(s *Server) {
+		s.subscriptions = st
+		s.preferences = st
+		s.sessions = st
+	}
+}
+
+// withSubscriptions keeps subscriptions in subscriptions, such as a store
+// that fails.
+func withSubscriptions(subscriptions store.SubscriptionStore) serverOption {
+	return func(s *Server) { s.subscriptions = subscriptions }
+}
+
+// withPreferences keeps preferences in preferences, such as a store that
+// fails.
+func withPreferences(preferences store.PreferenceStore) serverOption {
+	return func(s *Server) { s.preferences = preferences }
+}
+
+// withSessions keeps sessions in sessions, such as a store that fails.
+func withSessions(sessions store.SessionStore) serverOption {
+	return func(s *Server) { s.sessions = sessions }
+}
+
+// withOptOuts checks opt-outs in optOuts, which the server leaves to Twilio
+// otherwise.
+func withOptOuts(optOuts store.OptOutStore) serverOption {
+	return func(s *Server) { s.optOuts = optOuts }
+}
+
+func withNPS(npsClient nps.Client) serverOption {
+	return func(s *Server) { s.npsClient = npsClient }
+}
+
+func withTwilio(twilioClient twilio.Client) serverOption {
+	return func(s *Server) { s.twilioClient = twilioClient }
+}
+
+// withTwiML answers texts with TwiML instead of sending replies.
+func withTwiML() serverOption {
+	return func(s *Server) { s.twimlReplies = true }
+}
+
+func formRequest(data url.Values) *http.Request {
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(data.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// smsRequest is a text of body from the number the tests text from.
+func smsRequest(body string) *http.Request {
+	data := url.Values{}
+	data.Set("body", body)
+	data.Set("from", "+12407439754")
+
+	return formRequest(data)
+}
+
+// apiGet serves a GET of target from the API routes.
+func apiGet(s *Server, target string) *httptest.ResponseRecorder {
+	return apiGetWithKey(s, target, "")
+}
+
+// apiGetWithKey serves a GET of target from the API routes with key in the
+// API key header.
+func apiGetWithKey(s *Server, target, key string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Route("/api/v1", s.apiRoutes)
+
+	r := httptest.NewRequest("GET", target, nil)
+	if key != "" {
+		r.Header.Set(apiKeyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"go.uber.org/zap"
+)
+
+const (
+	homeSetMessage     = "Your home state is now %s. Text \"alerts\" to see its most recent alert."
+	homeStateMessage   = "Your home state is %s. Text \"alerts\" to see its most recent alert, or \"home\" followed by a 2-letter state code to change it."
+	noHomeStateMessage = `You haven't saved a home state. Text "home" followed by a 2-letter state code, like "home UT", and "alerts" will show alerts for it.`
+	badHomeMessage     = `I'm sorry, "%s" isn't a state code I recognize. Please text "home" followed by a 2-letter state code, like "home UT"`
+	unknownHomeMessage = `I'm sorry, I don't know which state to show alerts for. Text "alerts" followed by a state or park, like "alerts UT", or save a home state by texting "home UT"`
+)
+
+// homeHandler shows the sender's home state, or saves a new one when a
+// state code or name follows "home".
+func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	from := inboundMessageFromContext(ctx).From
+	target := strings.Join(args, " ")
+
+	prefs, err := s.preferences.Preferences(ctx, from)
+	if err != nil {
+		logger.Error("failed to load preferences", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if target == "" {
+		if name, ok := nps.StateName(prefs.HomeState); ok {
+			s.reply(w, r, from, tr(ctx, homeStateMessage, name), http.StatusOK)
+			return
+		}
+		s.reply(w, r, from, tr(ctx, noHomeStateMessage), http.StatusOK)
+		return
+	}
+
+	code, ok := nps.ResolveState(target)
+	if !ok {
+		s.reply(w, r, from, tr(ctx, badHomeMessage, target), http.StatusBadRequest)
+		return
+	}
+	name, _ := nps.StateName(code)
+
+	prefs.HomeState = code
+	if err := s.preferences.SetPreferences(ctx, from, prefs); err != nil {
+		logger.Error("failed to save preferences", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if s.reply(w, r, from, tr(ctx, homeSetMessage, name), http.StatusOK) {
+		logger.Info("saved home state", zap.String("state", prefs.HomeState))
+	}
+}
+
+// defaultState is the state "alerts" answers for when none is given: the
+// sender's saved home state, or else the state Twilio places their number
+// in. It returns an empty code when neither is known.
+func (s *Server) defaultState(ctx context.Context, msg inboundMessage) (string, error) {
+
+	if s.preferences != nil {
+		prefs, err := s.preferences.Preferences(ctx, msg.From)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := nps.StateName(prefs.HomeState); ok {
+			return strings.ToUpper(prefs.HomeState), nil
+		}
+	}
+
+	if _, ok := nps.StateName(msg.FromState); ok {
+		return strings.ToUpper(msg.FromState), nil
+	}
+
+	return "", nil
+}
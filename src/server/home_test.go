@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncomingSmsHomeSet(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("Home ut"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`Your home state is now Utah. Text "alerts" to see its most recent alert.`, twilioClient.lastMessage)
+
+	prefs, _ := st.Preferences(context.Background(), "+12407439754")
+	assert.Equal("UT", prefs.HomeState)
+}
+
+func TestIncomingSmsHomeInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("home ZZ"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(`I'm sorry, "ZZ" isn't a state code I recognize. Please text "home" followed by a 2-letter state code, like "home UT"`, twilioClient.lastMessage)
+
+	prefs, _ := st.Preferences(context.Background(), "+12407439754")
+	assert.Empty(prefs.HomeState)
+}
+
+func TestIncomingSmsHomeShow(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{HomeState: "AZ"})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("home"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Contains(twilioClient.lastMessage, "Your home state is Arizona.")
+}
+
+func TestIncomingSmsHomeShowUnset(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("home"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(noHomeStateMessage, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsHomeStoreErr(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient), withPreferences(failingStore{}))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("home UT"))
+
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+	assert.Equal(internalErrorMessage, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertsHomeState(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withStore(st), withNPS(npsClient))
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{HomeState: "UT"})
+
+	r := formRequest(url.Values{"From": {"+12407439754"}, "Body": {"Alerts"}, "FromState": {"CA"}})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("UT", npsClient.lastStateCode)
+}
+
+func TestIncomingSmsAlertsFromState(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	s := newTestServer(t, withNPS(npsClient))
+
+	r := formRequest(url.Values{"From": {"+12407439754"}, "Body": {"alerts closures"}, "FromState": {"CA"}})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("CA", npsClient.lastStateCode)
+	assert.Equal([]string{nps.CategoryClosure}, npsClient.lastOpts.Categories)
+}
+
+func TestIncomingSmsAlertsNoDefaultState(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+
+	// Twilio sends a province for Canadian numbers
+	r := formRequest(url.Values{"From": {"+12407439754"}, "Body": {"alerts"}, "FromState": {"ON"}})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(unknownHomeMessage, twilioClient.lastMessage)
+	assert.Empty(npsClient.lastStateCode)
+}
+
+func TestIncomingSmsAlertsPreferencesErr(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient), withPreferences(failingStore{}))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts"))
+
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+	assert.Equal(internalErrorMessage, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsHomeSetName(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	s := newTestServer(t, withStore(st))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("home north dakota"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+
+	prefs, _ := st.Preferences(context.Background(), "+12407439754")
+	assert.Equal("ND", prefs.HomeState)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// inboundMessage is the payload Twilio posts to the incoming message webhook.
+// See https://www.twilio.com/docs/messaging/guides/webhook-request
+type inboundMessage struct {
+	MessageSID string
+	AccountSID string
+	From       string
+	To         string
+	Body       string
+	NumMedia   int
+
+	// The From* fields are Twilio's best guess at where the sender is, based
+	// on their number. Any of them may be empty.
+	FromCity    string
+	FromState   string
+	FromZip     string
+	FromCountry string
+}
+
+type inboundMessageKey struct{}
+
+// missingFieldError is returned by parseInboundMessage when a required
+// parameter is absent or blank.
+type missingFieldError struct {
+	field string
+}
+
+func (e *missingFieldError) Error() string {
+	return fmt.Sprintf("missing field in request body: %s", e.field)
+}
+
+// parseInboundMessage reads the Twilio parameters from the form body of r.
+// Twilio capitalizes its parameter names, as in From and Body, while older
+// clients and the README examples use from and body, so both are accepted.
+func parseInboundMessage(r *http.Request) (inboundMessage, error) {
+
+	if err := r.ParseForm(); err != nil {
+		return inboundMessage{}, err
+	}
+
+	msg := inboundMessage{
+		MessageSID:  formField(r, "MessageSid"),
+		AccountSID:  formField(r, "AccountSid"),
+		From:        strings.TrimSpace(formField(r, "From")),
+		To:          formField(r, "To"),
+		Body:        formField(r, "Body"),
+		FromCity:    formField(r, "FromCity"),
+		FromState:   strings.ToUpper(formField(r, "FromState")),
+		FromZip:     formField(r, "FromZip"),
+		FromCountry: formField(r, "FromCountry"),
+	}
+
+	if numMedia := formField(r, "NumMedia"); numMedia != "" {
+		n, err := strconv.Atoi(numMedia)
+		if err != nil {
+			return inboundMessage{}, fmt.Errorf("invalid NumMedia %q", numMedia)
+		}
+		msg.NumMedia = n
+	}
+
+	if msg.From == "" {
+		return inboundMessage{}, &missingFieldError{field: "from"}
+	}
+	// a picture sent on its own has no body
+	if strings.TrimSpace(msg.Body) == "" && msg.NumMedia == 0 {
+		return inboundMessage{}, &missingFieldError{field: "body"}
+	}
+
+	return msg, nil
+}
+
+// formField returns the Twilio parameter name, falling back to its
+// lower-cased spelling.
+func formField(r *http.Request, name string) string {
+	if value := r.PostForm.Get(name); value != "" {
+		return value
+	}
+	return r.PostForm.Get(strings.ToLower(name))
+}
+
+// withInboundMessage returns a copy of ctx that carries msg to the command
+// handlers.
+func withInboundMessage(ctx context.Context, msg inboundMessage) context.Context {
+	return context.WithValue(ctx, inboundMessageKey{}, msg)
+}
+
+// inboundMessageFromContext returns the message being handled, or the zero
+// value when ctx does not carry one.
+func inboundMessageFromContext(ctx context.Context) inboundMessage {
+	msg, _ := ctx.Value(inboundMessageKey{}).(inboundMessage)
+	return msg
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseInboundMessageTwilioFields(t *testing.T) {
+	assert := assert.New(t)
+
+	msg, err := parseInboundMessage(formRequest(url.Values{
+		"MessageSid":  {"SM123"},
+		"AccountSid":  {"AC456"},
+		"From":        {"+14355551234"},
+		"To":          {"+15555550000"},
+		"Body":        {"alerts UT"},
+		"NumMedia":    {"2"},
+		"FromCity":    {"MOAB"},
+		"FromState":   {"ut"},
+		"FromZip":     {"84532"},
+		"FromCountry": {"US"},
+	}))
+
+	assert.Nil(err)
+	assert.Equal(inboundMessage{
+		MessageSID:  "SM123",
+		AccountSID:  "AC456",
+		From:        "+14355551234",
+		To:          "+15555550000",
+		Body:        "alerts UT",
+		NumMedia:    2,
+		FromCity:    "MOAB",
+		FromState:   "UT",
+		FromZip:     "84532",
+		FromCountry: "US",
+	}, msg)
+}
+
+func TestParseInboundMessageLowercaseFields(t *testing.T) {
+	assert := assert.New(t)
+
+	msg, err := parseInboundMessage(formRequest(url.Values{
+		"from": {"+14355551234"},
+		"body": {"help"},
+	}))
+
+	assert.Nil(err)
+	assert.Equal("+14355551234", msg.From)
+	assert.Equal("help", msg.Body)
+	assert.Equal(0, msg.NumMedia)
+}
+
+func TestParseInboundMessageBlankBody(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseInboundMessage(formRequest(url.Values{
+		"From": {"+14355551234"},
+		"Body": {"   "},
+	}))
+
+	assert.EqualError(err, "missing field in request body: body")
+}
+
+func TestParseInboundMessageMediaOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	msg, err := parseInboundMessage(formRequest(url.Values{
+		"From":     {"+14355551234"},
+		"Body":     {""},
+		"NumMedia": {"1"},
+	}))
+
+	assert.Nil(err)
+	assert.Equal(1, msg.NumMedia)
+}
+
+func TestParseInboundMessageBadNumMedia(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseInboundMessage(formRequest(url.Values{
+		"From":     {"+14355551234"},
+		"Body":     {"help"},
+		"NumMedia": {"lots"},
+	}))
+
+	assert.EqualError(err, `invalid NumMedia "lots"`)
+}
+
+func TestInboundMessageFromContextMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(inboundMessage{}, inboundMessageFromContext(context.Background()))
+}
+
+func TestIncomingSmsTwilioFieldNames(t *testing.T) {
+	assert := assert.New(t)
+
+	core, logs := observer.New(zap.InfoLevel)
+	twilioClient := &mockTwilioClient{}
+
+	s := Server{
+		npsClient:    &mockNpsClient{},
+		twilioClient: twilioClient,
+		logger:       zap.New(core),
+	}
+
+	r := formRequest(url.Values{
+		"MessageSid": {"SM123"},
+		"From":       {"+14355551234"},
+		"Body":       {"Help"},
+	})
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, r)
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(testHelpMessage(t), twilioClient.lastMessage)
+	assert.Equal("sent help message", logs.All()[0].Message)
+	assert.Equal("SM123", logs.All()[0].ContextMap()["messageSid"])
+}
+
+func TestIncomingSmsMissingFieldStops(t *testing.T) {
+	assert := assert.New(t)
+
+	core, logs := observer.New(zap.InfoLevel)
+	twilioClient := &mockTwilioClient{}
+
+	s := Server{
+		npsClient:    &mockNpsClient{},
+		twilioClient: twilioClient,
+		logger:       zap.New(core),
+	}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, formRequest(url.Values{"Body": {"help"}}))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Len(logs.All(), 1)
+	assert.Empty(twilioClient.lastMessage)
+}
+
+func TestIncomingSmsMediaOnlyGetsHelp(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+
+	s := Server{
+		npsClient:    &mockNpsClient{},
+		twilioClient: twilioClient,
+		logger:       zap.NewNop(),
+	}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, formRequest(url.Values{
+		"From":     {"+14355551234"},
+		"NumMedia": {"1"},
+	}))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(testHelpMessage(t), twilioClient.lastMessage)
+}
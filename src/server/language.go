@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"go.uber.org/zap"
+)
+
+const (
+	languageSetMessage = `OK, replies will now be in %s. Text "help" for a list of commands.`
+	languageMessage    = `Replies are in %s. Text "lang es" for Spanish or "lang en" for English.`
+	badLanguageMessage = `I'm sorry, "%s" isn't a language I know. Text "lang en" for English or "lang es" for Spanish.`
+)
+
+// spanishCategories maps the Spanish words texters use for alert categories
+// to NPS alert categories, alongside the English ones nps.ParseCategory knows.
+var spanishCategories = map[string]string{
+	"peligro":     nps.CategoryDanger,
+	"peligros":    nps.CategoryDanger,
+	"precaución":  nps.CategoryCaution,
+	"precaucion":  nps.CategoryCaution,
+	"información": nps.CategoryInformation,
+	"informacion": nps.CategoryInformation,
+	"cierre":      nps.CategoryClosure,
+	"cierres":     nps.CategoryClosure,
+	"cerrado":     nps.CategoryClosure,
+}
+
+type languageKey struct{}
+
+// withLanguage returns a copy of ctx whose replies are in lang.
+func withLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// languageFromContext returns the language replies are sent in, English when
+// ctx does not carry one.
+func languageFromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok {
+		return lang
+	}
+	return i18n.English
+}
+
+// tr translates message into the language of ctx and formats it with args,
+// like fmt.Sprintf.
+func tr(ctx context.Context, message string, args ...interface{}) string {
+	lang := languageFromContext(ctx)
+	if len(args) == 0 {
+		return i18n.Translate(lang, message)
+	}
+	return i18n.Sprintf(lang, message, args...)
+}
+
+// parseCategory resolves a category word in English or Spanish, such as
+// "closures" or "cierres", to an NPS alert category.
+func parseCategory(word string) (string, bool) {
+	if category, ok := spanishCategories[strings.ToLower(strings.TrimSpace(word))]; ok {
+		return category, true
+	}
+	return nps.ParseCategory(word)
+}
+
+// savedLanguage returns the language phone chose, or English when it has not
+// chosen one.
+func (s *Server) savedLanguage(ctx context.Context, phone string) (string, error) {
+	if s.preferences == nil {
+		return i18n.English, nil
+	}
+
+	prefs, err := s.preferences.Preferences(ctx, phone)
+	if err != nil {
+		return i18n.English, err
+	}
+	if prefs.Language == "" {
+		return i18n.English, nil
+	}
+	return prefs.Language, nil
+}
+
+// languageHandler shows the language replies are sent in, or saves a new one
+// when a language follows "lang".
+func (s *Server) languageHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	from := inboundMessageFromContext(ctx).From
+
+	target := strings.Join(args, " ")
+	if target == "" {
+		// "idioma" is answered in Spanish whatever the saved language is
+		saved, err := s.savedLanguage(ctx, from)
+		if err != nil {
+			logger.Error("failed to load preferences", zap.Error(err))
+			s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+			return
+		}
+		s.reply(w, r, from, tr(ctx, languageMessage, tr(ctx, i18n.Name(saved))), http.StatusOK)
+		return
+	}
+
+	lang, ok := i18n.ParseLanguage(target)
+	if !ok {
+		s.reply(w, r, from, tr(ctx, badLanguageMessage, target), http.StatusBadRequest)
+		return
+	}
+
+	s.setLanguage(w, r, lang)
+}
+
+// languageSetter returns a handler for a command that switches replies to
+// lang, like "español".
+func languageSetter(lang string) func(s *Server, w http.ResponseWriter, r *http.Request, args []string) {
+	return func(s *Server, w http.ResponseWriter, r *http.Request, args []string) {
+		s.setLanguage(w, r, lang)
+	}
+}
+
+// setLanguage saves lang as the sender's language and confirms it in lang.
+func (s *Server) setLanguage(w http.ResponseWriter, r *http.Request, lang string) {
+	ctx := withLanguage(r.Context(), lang)
+	r = r.WithContext(ctx)
+	logger := logging.FromContext(ctx)
+	from := inboundMessageFromContext(ctx).From
+
+	prefs, err := s.preferences.Preferences(ctx, from)
+	if err != nil {
+		logger.Error("failed to load preferences", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	prefs.Language = lang
+	if err := s.preferences.SetPreferences(ctx, from, prefs); err != nil {
+		logger.Error("failed to save preferences", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if s.reply(w, r, from, tr(ctx, languageSetMessage, tr(ctx, i18n.Name(lang))), http.StatusOK) {
+		logger.Info("saved language", zap.String("language", lang))
+	}
+}
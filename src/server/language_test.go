@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepliesTranslated(t *testing.T) {
+	assert := assert.New(t)
+
+	messages := []string{
+		invalidStateMessage, unknownTargetMessage, didYouMeanMessage, rateLimitedMessage,
+		unavailableMessage, internalErrorMessage, noAlertsMessage, statesAlertMessage,
+		stateAlertSection, stateNoAlerts, alertsLabel, categoryAlertsLabel, homeSetMessage, homeStateMessage,
+		noHomeStateMessage, badHomeMessage, unknownHomeMessage, optInMessage, alertsPageMessage,
+		alertsPageMoreHint, alertsPageLastHint, noSessionMessage, noMoreMessage, badReadMessage,
+		badSubscribeMessage, subscribedMessage, alreadySubscribedMessage, unsubscribedMessage,
+		notSubscribedMessage, listMessage, noSubscriptionsMessage, languageSetMessage,
+		languageMessage, badLanguageMessage, "and", "or", "English", "Spanish",
+		"danger", "caution", "information", "park closure",
+	}
+	for _, c := range commands {
+		messages = append(messages, c.usage...)
+	}
+
+	for _, message := range messages {
+		_, ok := i18n.Lookup(i18n.Spanish, message)
+		assert.True(ok, "no Spanish translation of %q", message)
+	}
+}
+
+func TestIncomingSmsLanguageSet(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{HomeState: "UT"})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("lang es"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`Listo, las respuestas ahora seran en español. Envia "ayuda" para ver la lista de comandos.`, twilioClient.lastMessage)
+
+	prefs, _ := st.Preferences(context.Background(), "+12407439754")
+	assert.Equal(store.Preferences{HomeState: "UT", Language: i18n.Spanish}, prefs)
+}
+
+func TestIncomingSmsLanguageWord(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("Español"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.True(strings.HasPrefix(twilioClient.lastMessage, "Listo"))
+
+	w = httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("english"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`OK, replies will now be in English. Text "help" for a list of commands.`, twilioClient.lastMessage)
+
+	prefs, _ := st.Preferences(context.Background(), "+12407439754")
+	assert.Equal(i18n.English, prefs.Language)
+}
+
+func TestIncomingSmsLanguageShow(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{Language: i18n.Spanish})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("lang"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`Las respuestas estan en español. Envia "idioma es" para español o "idioma en" para inglés.`, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsLanguageUnknown(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("lang klingon"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(`I'm sorry, "klingon" isn't a language I know. Text "lang en" for English or "lang es" for Spanish.`, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsLanguageSaveFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t, withPreferences(failingStore{}))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("idioma es"))
+
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestIncomingSmsSpanishCommandWord(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = []nps.AlertDetails{{
+		FullStateName:   "California",
+		FullParkName:    "Yosemite",
+		StateCode:       "CA",
+		RecentAlertTime: time.Date(2022, 8, 2, 9, 0, 0, 0, time.UTC),
+		AlertHeader:     "TEST_HEADER",
+		AlertMessage:    "TEST_MESSAGE",
+	}}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alertas CA cierres"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("Esta es la alerta de cierre de parque mas reciente del NPS para California, de Yosemite, publicada el 2 de agosto a las 02:00 PDT (hace 3 horas):\n\nTEST_HEADER\n\nTEST_MESSAGE", twilioClient.lastMessage)
+	assert.Equal([]string{nps.CategoryClosure}, npsClient.lastOpts.Categories)
+}
+
+func TestParseCategory(t *testing.T) {
+	assert := assert.New(t)
+
+	for word, want := range map[string]string{
+		"Cierres":     nps.CategoryClosure,
+		"precaución":  nps.CategoryCaution,
+		" peligro":    nps.CategoryDanger,
+		"informacion": nps.CategoryInformation,
+		"closures":    nps.CategoryClosure,
+	} {
+		category, ok := parseCategory(word)
+		assert.True(ok, word)
+		assert.Equal(want, category, word)
+	}
+
+	_, ok := parseCategory("yosemite")
+	assert.False(ok)
+}
+
+func TestIncomingSmsSavedLanguage(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{Language: i18n.Spanish})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("No hay alertas del NPS vigentes para CA.", twilioClient.lastMessage)
+}
+
+func TestIncomingSmsSpanishCategoryLabel(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alertas CA cierres"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("No hay alertas de cierre de parque del NPS vigentes para CA.", twilioClient.lastMessage)
+}
+
+func TestIncomingSmsEnglishWordKeepsSavedLanguage(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withTwilio(twilioClient))
+	_ = st.SetPreferences(context.Background(), "+12407439754", store.Preferences{Language: i18n.Spanish})
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("help"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.True(strings.HasPrefix(twilioClient.lastMessage, "¡Bienvenido a NPS alerts!"))
+}
+
+func TestIncomingSmsLanguageLoadFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient), withPreferences(failingStore{}))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("help"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.True(strings.HasPrefix(twilioClient.lastMessage, "Welcome to NPS alerts!"))
+}
+
+func TestHelpMessageSpanish(t *testing.T) {
+	assert := assert.New(t)
+
+	message, err := defaultReplyTemplates.render(i18n.Spanish, helpTemplate, helpFor(commands, i18n.Spanish))
+
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(message, "¡Bienvenido a NPS alerts! Esta es la lista de comandos:\n\nAyuda: recibe este texto de ayuda\n\nAlertas {estado}:"))
+	assert.NotContains(message, "Text ")
+}
+
+func TestHelpForSpanishNames(t *testing.T) {
+	assert := assert.New(t)
+
+	data := helpFor(commands, i18n.Spanish)
+
+	assert.Equal("ayuda", data.Commands[0].Name)
+	assert.Equal("alertas", data.Commands[1].Name)
+	assert.Equal([]string{"alerta"}, data.Commands[1].Aliases)
+}
+
+func TestRouteSpanish(t *testing.T) {
+	assert := assert.New(t)
+
+	c, ok := route("más", nil)
+	assert.True(ok)
+	assert.Equal("more", c.name)
+	assert.Equal(i18n.Spanish, c.language("más"))
+	assert.Equal("", c.language("more"))
+}
+
+func TestIncomingSmsLanguageShowSpanishWord(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("idioma"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`Las respuestas estan en inglés. Envia "idioma es" para español o "idioma en" para inglés.`, twilioClient.lastMessage)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"go.uber.org/zap"
+)
+
+const optInMessage = `You're opted back in to NPS alerts and your subscriptions are active again. Text "help" for a list of commands or "stop" to opt out.`
+
+// optOutHandler records that the sender opted out. It does not reply: Twilio
+// answers opt-out keywords with its own confirmation and blocks anything
+// else sent to the number afterwards. Subscriptions are kept so opting back
+// in restores them.
+func (s *Server) optOutHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	if _, err := s.optOuts.OptOut(ctx, inboundMessageFromContext(ctx).From); err != nil {
+		logger.Error("failed to save opt-out", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("opted out")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) optInHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	from := inboundMessageFromContext(ctx).From
+
+	if _, err := s.optOuts.OptIn(ctx, from); err != nil {
+		logger.Error("failed to remove opt-out", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if s.reply(w, r, from, tr(ctx, optInMessage), http.StatusOK) {
+		logger.Info("opted in")
+	}
+}
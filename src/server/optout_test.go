@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/WilliamDeBruin/nps_alerts/src/twilio"
+	"github.com/stretchr/testify/assert"
+)
+
+// optOutTwilioClient refuses to text numbers in the opt-out store, like the
+// real client configured with WithOptOutChecker.
+type optOutTwilioClient struct {
+	mockTwilioClient
+	optOuts store.OptOutStore
+}
+
+func (m *optOutTwilioClient) SendMessage(ctx context.Context, to, message string) error {
+	if optedOut, _ := m.optOuts.IsOptedOut(ctx, to); optedOut {
+		return twilio.ErrOptedOut
+	}
+	return m.mockTwilioClient.SendMessage(ctx, to, message)
+}
+
+func TestIncomingSmsStop(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &optOutTwilioClient{optOuts: st}
+	s := newTestServer(t, withStore(st), withOptOuts(st), withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest(" STOP "))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Empty(twilioClient.lastMessage)
+
+	optedOut, _ := st.IsOptedOut(context.Background(), "+12407439754")
+	assert.True(optedOut)
+}
+
+func TestIncomingSmsOptOutKeywords(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, keyword := range []string{"Stop", "stopall", "UNSUBSCRIBE", "cancel", "End", "quit"} {
+		st := store.NewMemory()
+		s := newTestServer(t, withStore(st), withOptOuts(st), withTwilio(&optOutTwilioClient{optOuts: st}))
+
+		s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest(keyword))
+
+		optedOut, _ := st.IsOptedOut(context.Background(), "+12407439754")
+		assert.True(optedOut, keyword)
+	}
+}
+
+func TestIncomingSmsUnsubscribeTopicIsNotOptOut(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	s := newTestServer(t, withStore(st), withOptOuts(st), withTwilio(&optOutTwilioClient{optOuts: st}))
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("unsubscribe UT"))
+
+	optedOut, _ := st.IsOptedOut(context.Background(), "+12407439754")
+	assert.False(optedOut)
+}
+
+func TestIncomingSmsOptedOutGetsNoReply(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &optOutTwilioClient{optOuts: st}
+	s := newTestServer(t, withStore(st), withOptOuts(st), withTwilio(twilioClient))
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("stop"))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("list"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Empty(twilioClient.lastMessage)
+}
+
+func TestIncomingSmsStart(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &optOutTwilioClient{optOuts: st}
+	s := newTestServer(t, withStore(st), withOptOuts(st), withTwilio(twilioClient))
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("stop"))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("Unstop"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(optInMessage, twilioClient.lastMessage)
+
+	optedOut, _ := st.IsOptedOut(context.Background(), "+12407439754")
+	assert.False(optedOut)
+}
+
+func TestIncomingSmsInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	twilioClient := &optOutTwilioClient{optOuts: st}
+	s := newTestServer(t, withStore(st), withOptOuts(st), withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("INFO"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(testHelpMessage(t), twilioClient.lastMessage)
+}
@@ -1,14 +1,18 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/WilliamDeBruin/nps_alerts/src/config"
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
 	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/poller"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
 	"github.com/WilliamDeBruin/nps_alerts/src/twilio"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -18,12 +22,73 @@ import (
 	"github.com/pkg/errors"
 )
 
+// shutdownTimeout is how long Close waits for in-flight requests to finish,
+// under the 10 seconds Docker and most process managers allow after SIGTERM.
+const shutdownTimeout = 8 * time.Second
+
 type Server struct {
-	twilioClient twilio.Client
-	npsClient    nps.Client
-	httpServer   *http.Server
-	port         string
-	logger       *zap.Logger
+	twilioClient   twilio.Client
+	npsClient      nps.Client
+	parks          *nps.Directory
+	subscriptions  store.SubscriptionStore
+	optOuts        store.OptOutStore
+	preferences    store.PreferenceStore
+	sessions       store.SessionStore
+	storage        store.Store
+	httpServer     *http.Server
+	port           string
+	logger         *zap.Logger
+	requestTimeout time.Duration
+	now            func() time.Time
+
+	// lookupTimeout bounds the NPS lookup for an "alerts" text and
+	// replyTimeout the reply, so that the reply goes out after a lookup that
+	// ran out of time. Zero leaves them to the request timeout.
+	lookupTimeout time.Duration
+	replyTimeout  time.Duration
+
+	twilioAuthToken   string
+	twilioWebhookURL  string
+	validateSignature bool
+	twimlReplies      bool
+
+	// maxSegments is the SMS segment budget for alert texts. Zero is no limit.
+	maxSegments int
+
+	// templates render replies. Nil uses the embedded defaults.
+	templates *replyTemplates
+
+	// apiKeys are the keys the JSON API accepts. Empty leaves it open.
+	apiKeys []string
+
+	// apiLimiter limits JSON API requests per client. Nil is no limit.
+	apiLimiter *rateLimiter
+
+	// apiTrustProxy takes the address of JSON API clients from the headers
+	// set by a proxy in front of the server.
+	apiTrustProxy bool
+
+	// sessionIdleTimeout is how long a list of alerts can be paged through
+	// after it was last used. Zero keeps sessions until they are replaced.
+	sessionIdleTimeout time.Duration
+
+	parksRefreshInterval time.Duration
+
+	poller       *poller.Poller
+	pollInterval time.Duration
+
+	// cacheStatsInterval is how often the NPS cache counters are logged.
+	cacheStatsInterval time.Duration
+
+	// background tracks the goroutines started by Serve so Close can wait
+	// for them to stop.
+	background sync.WaitGroup
+
+	// ctx is the base context of every request, cancelled on Close after
+	// in-flight requests have had shutdownTimeout to finish, so that outbound
+	// calls stop when the server shuts down.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewServer(
@@ -31,37 +96,152 @@ func NewServer(
 	logger *zap.Logger,
 ) (*Server, error) {
 
-	twilioClient, err := twilio.NewClient(cfg.TwilioFromNumber)
+	st, err := store.Open(cfg.StoreDriver, cfg.StorePath)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing store: %s", err)
+	}
+
+	if cfg.StoreDriver == "" && cfg.StorePath == "" {
+		logger.Warn("STORE_DRIVER and STORE_PATH are not set, so subscriptions, opt-outs and preferences are kept in memory and lost on restart")
+	}
+
+	s, err := newServer(cfg, logger, st)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// newServer builds the server around an open store, which NewServer closes
+// when this fails.
+func newServer(
+	cfg *config.Configuration,
+	logger *zap.Logger,
+	st store.Store,
+) (*Server, error) {
+
+	twilioClient, err := twilio.NewClient(cfg.TwilioFromNumber,
+		twilio.WithOptOutChecker(st),
+		twilio.WithTimeout(cfg.TwilioTimeout),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing twilio client: %s", err)
 	}
 
-	npsClient, err := nps.NewClient(cfg.NPSApiKey)
+	parks, err := nps.NewDirectory(cfg.NPSApiKey, cfg.NPSParksSnapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing park directory: %s", err)
+	}
+
+	npsClient, err := nps.NewClient(cfg.NPSApiKey,
+		nps.WithDirectory(parks),
+		nps.WithTimeout(cfg.NPSTimeout),
+		nps.WithRetries(cfg.NPSMaxRetries, cfg.NPSRetryBaseDelay, cfg.NPSRetryMaxDelay),
+		nps.WithCircuitBreaker(cfg.NPSBreakerThreshold, cfg.NPSBreakerCooldown),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing nps client: %s", err)
 	}
 
+	if cfg.NPSCacheTTL > 0 {
+		npsClient = nps.NewCachingClient(npsClient, cfg.NPSCacheTTL)
+	}
+
+	templates, err := loadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading reply templates: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	s := &Server{
-		twilioClient: twilioClient,
-		npsClient:    npsClient,
-		port:         cfg.Port,
-		logger:       logger,
+		twilioClient:   twilioClient,
+		npsClient:      npsClient,
+		parks:          parks,
+		subscriptions:  st,
+		optOuts:        st,
+		preferences:    st,
+		sessions:       st,
+		storage:        st,
+		port:           cfg.Port,
+		logger:         logger,
+		requestTimeout: cfg.RequestTimeout,
+		lookupTimeout:  cfg.LookupTimeout,
+		replyTimeout:   cfg.TwilioTimeout,
+		ctx:            ctx,
+		cancel:         cancel,
+
+		twilioAuthToken:   cfg.TwilioAuthToken,
+		twilioWebhookURL:  cfg.TwilioWebhookURL,
+		validateSignature: cfg.TwilioValidateSignature,
+		twimlReplies:      cfg.TwilioTwiMLReplies,
+		maxSegments:       cfg.SMSMaxSegments,
+		templates:         templates,
+
+		apiKeys:       cfg.APIKeys,
+		apiLimiter:    newRateLimiter(cfg.APIRateLimit),
+		apiTrustProxy: cfg.APITrustProxy,
+
+		sessionIdleTimeout: cfg.SessionIdleTimeout,
+
+		parksRefreshInterval: cfg.NPSParksRefreshInterval,
+		pollInterval:         cfg.PollInterval,
+		cacheStatsInterval:   cfg.NPSCacheStatsInterval,
 	}
+	s.poller = poller.New(npsClient, twilioClient, st, s.formatNewAlert)
 
 	return s, nil
 }
 
-func (s *Server) Serve() {
-	defer s.Close()
+// Serve handles requests until ctx is cancelled, then shuts the server down
+// with Close. It returns an error when the server stops for any other reason.
+func (s *Server) Serve(ctx context.Context) error {
+	defer func() {
+		if err := s.Close(); err != nil {
+			s.logger.Error(fmt.Sprintf("failed to shut down cleanly: %s", err))
+		}
+	}()
+
+	bg := logging.WithLogger(s.ctx, s.logger)
+
+	if s.parksRefreshInterval > 0 {
+		s.goBackground(func() { s.parks.Run(bg, s.parksRefreshInterval) })
+	}
+
+	if s.pollInterval > 0 {
+		s.goBackground(func() { s.poller.Run(bg, s.pollInterval) })
+	}
+
+	if s.sessions != nil && s.sessionIdleTimeout > 0 {
+		s.goBackground(func() { s.expireSessions(bg) })
+	}
+
+	if cache, ok := s.npsClient.(*nps.CachingClient); ok && s.cacheStatsInterval > 0 {
+		s.goBackground(func() { logCacheStats(bg, cache, s.cacheStatsInterval) })
+	}
 
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", s.port))
 	if err != nil {
-		panic(fmt.Sprintf("unable to serve: %s", err))
+		return errors.Wrap(err, "unable to serve")
 	}
 
 	s.listen(listener)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.httpServer.Serve(listener) }()
+
+	select {
+	case <-ctx.Done():
+		s.logger.Info("shutting down")
+		return nil
+	case err := <-serveErr:
+		return errors.Wrap(err, "server crash")
+	}
 }
 
+// listen sets up the HTTP server for the routes on listener.
 func (s *Server) listen(listener net.Listener) {
 	router := chi.NewRouter()
 
@@ -70,32 +250,62 @@ func (s *Server) listen(listener net.Listener) {
 	router.Use(zapchi.Logger(s.logger, "router"))
 
 	router.Get("/health", s.HealthHandler)
-	router.Post("/incoming-sms", s.IncomingSmsHandler)
+
+	router.Route("/api/v1", s.apiRoutes)
+
+	router.Group(func(r chi.Router) {
+		if s.validateSignature {
+			r.Use(s.requireTwilioSignature)
+		} else {
+			s.logger.Warn("twilio webhook signatures are not being validated")
+		}
+		r.Post("/incoming-sms", s.IncomingSmsHandler)
+	})
 
 	port := listener.Addr().(*net.TCPAddr).Port
 	s.port = fmt.Sprintf("%d", port)
 	s.logger.Info(fmt.Sprintf("listening on %d", port))
 
 	s.httpServer = &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: router}
+	s.httpServer.BaseContext = func(net.Listener) context.Context { return s.ctx }
 	s.httpServer.WriteTimeout = 1 * time.Minute
 	s.httpServer.ReadTimeout = 1 * time.Minute
+}
 
-	if err := s.httpServer.Serve(listener); err != nil {
-		if err != http.ErrServerClosed {
-			s.logger.Error(fmt.Sprintf("server crash: %v", err))
-			os.Exit(1)
-		}
-	}
+// goBackground runs fn in a goroutine that Close waits for. fn must return
+// once s.ctx is cancelled.
+func (s *Server) goBackground(fn func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
 }
 
 // Close closes all db connections or any other clean up
 func (srv *Server) Close() error {
 	// potentially doing many things that could error. Keep all errors and return at the end.
 	var errs error
-	// close socket to stop new requests from coming in
-	err := srv.httpServer.Close()
-	if err != nil {
-		errs = errors.Wrap(err, "error closing http server")
+	// stop taking new requests and let in-flight webhooks finish
+	if srv.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.httpServer.Shutdown(ctx); err != nil {
+			errs = errors.Wrap(err, "error shutting down http server")
+			_ = srv.httpServer.Close()
+		}
+	}
+	// cancel the outbound calls of anything still running
+	if srv.cancel != nil {
+		srv.cancel()
+	}
+	// let the park refresh and alert poller finish what they were doing
+	srv.background.Wait()
+	// close the store last, once nothing is using it
+	if srv.storage != nil {
+		if err := srv.storage.Close(); err != nil && errs == nil {
+			errs = errors.Wrap(err, "error closing store")
+		}
 	}
 
 	return errs
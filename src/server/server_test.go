@@ -1,11 +1,18 @@
 package server
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/WilliamDeBruin/nps_alerts/src/config"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNewServer(t *testing.T) {
@@ -23,6 +30,124 @@ func TestNewServer(t *testing.T) {
 	assert.Nil(err)
 }
 
+func TestNewServerWarnsImplicitMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+	}
+	core, logs := observer.New(zap.WarnLevel)
+
+	s, err := NewServer(cfg, zap.New(core))
+
+	assert.Nil(err)
+	assert.IsType(&store.Memory{}, s.storage)
+	assert.Equal(1, logs.FilterMessageSnippet("kept in memory").Len())
+}
+
+func TestNewServerExplicitMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		StoreDriver:      "memory",
+	}
+	core, logs := observer.New(zap.WarnLevel)
+
+	_, err := NewServer(cfg, zap.New(core))
+
+	assert.Nil(err)
+	assert.Equal(0, logs.FilterMessageSnippet("kept in memory").Len())
+}
+
+func TestNewServerCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		NPSCacheTTL:      time.Minute,
+	}
+	logger := zaptest.NewLogger(t)
+
+	s, err := NewServer(cfg, logger)
+
+	assert.Nil(err)
+	assert.IsType(&nps.CachingClient{}, s.npsClient)
+}
+
+func TestNewServerStorePath(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		StorePath:        filepath.Join(t.TempDir(), "subscriptions.json"),
+	}
+	logger := zaptest.NewLogger(t)
+
+	s, err := NewServer(cfg, logger)
+
+	assert.Nil(err)
+	assert.IsType(&store.File{}, s.subscriptions)
+}
+
+func TestNewServerSQLiteStore(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		StoreDriver:      "sqlite",
+		StorePath:        filepath.Join(t.TempDir(), "nps_alerts.db"),
+	}
+	logger := zaptest.NewLogger(t)
+
+	s, err := NewServer(cfg, logger)
+
+	assert.Nil(err)
+	assert.IsType(&store.SQLite{}, s.storage)
+	assert.Nil(s.storage.Close())
+}
+
+func TestNewServerBadStoreDriver(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		StoreDriver:      "postgres",
+	}
+	logger := zaptest.NewLogger(t)
+
+	s, err := NewServer(cfg, logger)
+
+	assert.Nil(s)
+	assert.EqualError(err, `error initializing store: unknown store driver "postgres"`)
+}
+
+func TestNewServerBadTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "alert.tmpl", "{{.AlertHeader")
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		TemplatesDir:     dir,
+	}
+	logger := zaptest.NewLogger(t)
+
+	s, err := NewServer(cfg, logger)
+
+	assert.Nil(s)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "error loading reply templates: template: alert.tmpl")
+}
+
 func TestNewServerMissingParams(t *testing.T) {
 	assert := assert.New(t)
 
@@ -54,3 +179,47 @@ func TestNewServerMissingParams(t *testing.T) {
 // 	s, err := NewServer(cfg, logger)
 
 // }
+
+func TestServeStopsOnCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+		Port:             "0",
+	}
+	s, err := NewServer(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx) }()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Nil(err)
+		assert.NotNil(s.ctx.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not stop after the context was cancelled")
+	}
+}
+
+func TestCloseBeforeServe(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Configuration{
+		TwilioFromNumber: "+123456789",
+		NPSApiKey:        "TEST_KEY",
+	}
+	s, err := NewServer(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(s.Close())
+}
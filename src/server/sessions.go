@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"go.uber.org/zap"
+)
+
+const (
+	// alertsPageSize is how many headlines each page of alerts lists.
+	alertsPageSize = 5
+
+	// maxSessionAlerts caps how many alerts a texter can page through.
+	maxSessionAlerts = 50
+
+	alertsPageMessage  = "NPS %s for %s, %d-%d of %d:\n\n%s\n\n%s"
+	alertsPageMoreHint = `Text "read" and a number to read an alert in full, or "more" for the next page.`
+	alertsPageLastHint = `Text "read" and a number to read an alert in full.`
+
+	noSessionMessage = `There is no list of alerts to page through. Text "alerts" followed by a state or park, like "alerts CA", to start one.`
+	noMoreMessage    = `There are no more %s for %s. Text "read" followed by a number from 1 to %d to read one in full.`
+	badReadMessage   = `Text "read" followed by a number from 1 to %d, like "read 1".`
+)
+
+// moreHandler sends the next page of the sender's list of alerts.
+func (s *Server) moreHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	from := inboundMessageFromContext(ctx).From
+
+	session, ok, err := s.loadSession(ctx, from)
+	if err != nil {
+		logger.Error("failed to load session", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.reply(w, r, from, tr(ctx, noSessionMessage), http.StatusBadRequest)
+		return
+	}
+
+	if session.Next >= len(session.Alerts) {
+		s.reply(w, r, from, tr(ctx, noMoreMessage, formatAlertsLabel(languageFromContext(ctx), session.Category), session.Name, len(session.Alerts)), http.StatusOK)
+		return
+	}
+
+	message := s.formatAlertsPage(languageFromContext(ctx), &session)
+
+	if err := s.saveSession(ctx, from, session); err != nil {
+		logger.Error("failed to save session", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if s.reply(w, r, from, message, http.StatusOK) {
+		logger.Info("sent alerts page", zap.Int("next", session.Next))
+	}
+}
+
+// readHandler sends the full text of one alert from the sender's list.
+func (s *Server) readHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	from := inboundMessageFromContext(ctx).From
+
+	session, ok, err := s.loadSession(ctx, from)
+	if err != nil {
+		logger.Error("failed to load session", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.reply(w, r, from, tr(ctx, noSessionMessage), http.StatusBadRequest)
+		return
+	}
+
+	n := 0
+	if len(args) == 1 {
+		n, _ = strconv.Atoi(args[0])
+	}
+	if n < 1 || n > len(session.Alerts) {
+		s.reply(w, r, from, tr(ctx, badReadMessage, len(session.Alerts)), http.StatusBadRequest)
+		return
+	}
+
+	// reading keeps the session alive
+	if err := s.saveSession(ctx, from, session); err != nil {
+		logger.Error("failed to save session", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	alert := sessionAlertDetails(session, session.Alerts[n-1])
+	message, err := s.formatAlert(languageFromContext(ctx), alert, session.Topic.Kind == store.TopicState, session.Category)
+	if err != nil {
+		logger.Error("failed to render alert", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if s.reply(w, r, from, message, http.StatusOK) {
+		logger.Info("sent alert from list", zap.Int("number", n))
+	}
+}
+
+// loadSession returns the sender's session, unless it has been idle for
+// longer than the session timeout.
+func (s *Server) loadSession(ctx context.Context, phone string) (store.Session, bool, error) {
+	if s.sessions == nil {
+		return store.Session{}, false, nil
+	}
+
+	session, ok, err := s.sessions.Session(ctx, phone)
+	if err != nil || !ok {
+		return store.Session{}, false, err
+	}
+
+	if s.sessionIdleTimeout > 0 && s.clock().Sub(session.UpdatedAt) > s.sessionIdleTimeout {
+		return store.Session{}, false, s.sessions.DeleteSession(ctx, phone)
+	}
+
+	return session, true, nil
+}
+
+// saveSession stores session as the sender's, last used now.
+func (s *Server) saveSession(ctx context.Context, phone string, session store.Session) error {
+	if s.sessions == nil {
+		return nil
+	}
+
+	session.UpdatedAt = s.clock()
+	return s.sessions.SetSession(ctx, phone, session)
+}
+
+// expireSessions removes idle sessions every session timeout until ctx is
+// cancelled.
+func (s *Server) expireSessions(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	ticker := time.NewTicker(s.sessionIdleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.sessions.ExpireSessions(ctx, s.clock().Add(-s.sessionIdleTimeout))
+			if err != nil {
+				logger.Error("failed to expire sessions", zap.Error(err))
+				continue
+			}
+			if expired > 0 {
+				logger.Info("expired idle sessions", zap.Int("sessions", expired))
+			}
+		}
+	}
+}
+
+// newSession starts a session over alerts found for a state, when isState
+// is set, or a park. alerts must be newest first.
+func newSession(states []string, isState bool, category string, alerts []nps.AlertDetails) store.Session {
+	session := store.Session{Category: category}
+
+	if isState {
+		session.Topic = store.Topic{Kind: store.TopicState, Code: states[0]}
+		session.Name = alerts[0].FullStateName
+	} else {
+		session.Topic = store.Topic{Kind: store.TopicPark, Code: alerts[0].ParkCode}
+		session.Name = alerts[0].FullParkName
+	}
+
+	for _, alert := range alerts {
+		session.Alerts = append(session.Alerts, store.SessionAlert{
+			ParkName:      alert.FullParkName,
+			StateCode:     alert.StateCode,
+			Header:        alert.AlertHeader,
+			Message:       alert.AlertMessage,
+			URL:           alert.URL,
+			Published:     alert.RecentAlertTime,
+			PublishedDate: alert.RecentAlertDate,
+		})
+	}
+
+	return session
+}
+
+// sessionAlertDetails turns an alert kept in session back into the details
+// the reply is formatted from.
+func sessionAlertDetails(session store.Session, alert store.SessionAlert) nps.AlertDetails {
+	details := nps.AlertDetails{
+		FullParkName:    alert.ParkName,
+		StateCode:       alert.StateCode,
+		AlertHeader:     alert.Header,
+		AlertMessage:    alert.Message,
+		URL:             alert.URL,
+		RecentAlertTime: alert.Published,
+		RecentAlertDate: alert.PublishedDate,
+	}
+	if session.Topic.Kind == store.TopicState {
+		details.FullStateName = session.Name
+	}
+	return details
+}
+
+// formatAlertsPage renders the numbered headlines of the next page of
+// session in lang and moves session on to the page after. Headlines are
+// shortened to fit the segment budget.
+func (s *Server) formatAlertsPage(lang string, session *store.Session) string {
+	first := session.Next
+	last := first + alertsPageSize
+	if last > len(session.Alerts) {
+		last = len(session.Alerts)
+	}
+
+	headlines := make([]string, 0, last-first)
+	for i := first; i < last; i++ {
+		headlines = append(headlines, session.Alerts[i].Header)
+	}
+
+	session.Next = last
+
+	hint := alertsPageMoreHint
+	if last == len(session.Alerts) {
+		hint = alertsPageLastHint
+	}
+
+	return s.fitHeadlines(headlines, func(headlines []string) string {
+		lines := make([]string, 0, len(headlines))
+		for i, headline := range headlines {
+			n := first + i
+			if session.Topic.Kind == store.TopicState {
+				lines = append(lines, fmt.Sprintf("%d. %s: %s", n+1, session.Alerts[n].ParkName, headline))
+			} else {
+				lines = append(lines, fmt.Sprintf("%d. %s", n+1, headline))
+			}
+		}
+
+		return i18n.Sprintf(lang, alertsPageMessage,
+			formatAlertsLabel(lang, session.Category),
+			session.Name,
+			first+1,
+			last,
+			len(session.Alerts),
+			strings.Join(lines, "\n"),
+			i18n.Translate(lang, hint))
+	})
+}
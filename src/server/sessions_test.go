@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/sms"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// stateAlerts returns n California alerts, newest first.
+func stateAlerts(n int) []nps.AlertDetails {
+	alerts := make([]nps.AlertDetails, 0, n)
+	for i := 1; i <= n; i++ {
+		alerts = append(alerts, nps.AlertDetails{
+			StateCode:       "CA",
+			FullStateName:   "California",
+			ParkCode:        "yose",
+			FullParkName:    fmt.Sprintf("PARK_%d", i),
+			RecentAlertDate: fmt.Sprintf("DATE_%d", i),
+			AlertHeader:     fmt.Sprintf("HEADER_%d", i),
+			AlertMessage:    fmt.Sprintf("MESSAGE_%d", i),
+			URL:             fmt.Sprintf("URL_%d", i),
+		})
+	}
+	return alerts
+}
+
+func TestIncomingSmsAlertList(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = stateAlerts(7)
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("NPS alerts for California, 1-5 of 7:\n\n"+
+		"1. PARK_1: HEADER_1\n2. PARK_2: HEADER_2\n3. PARK_3: HEADER_3\n4. PARK_4: HEADER_4\n5. PARK_5: HEADER_5\n\n"+
+		alertsPageMoreHint, twilioClient.lastMessage)
+
+	session, ok, _ := st.Session(context.Background(), "+12407439754")
+	assert.True(ok)
+	assert.Equal(store.Topic{Kind: store.TopicState, Code: "CA"}, session.Topic)
+	assert.Equal(5, session.Next)
+	assert.Len(session.Alerts, 7)
+	assert.Equal(s.clock(), session.UpdatedAt)
+}
+
+func TestIncomingSmsAlertListPark(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = stateAlerts(2)
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts yose closures"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("NPS park closure alerts for PARK_1, 1-2 of 2:\n\n1. HEADER_1\n2. HEADER_2\n\n"+alertsPageLastHint, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsAlertListSegmentBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	s.maxSegments = 2
+	npsClient.getAlertsResponse = stateAlerts(7)
+	for i := range npsClient.getAlertsResponse {
+		npsClient.getAlertsResponse[i].AlertHeader = strings.Repeat("Road closed for construction ", 5)
+	}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.LessOrEqual(sms.Measure(twilioClient.lastMessage).Segments, 2)
+	assert.Contains(twilioClient.lastMessage, "1. PARK_1: Road closed")
+	assert.Contains(twilioClient.lastMessage, "...\n5. PARK_5: Road closed")
+	assert.True(strings.HasSuffix(twilioClient.lastMessage, alertsPageMoreHint))
+}
+
+func TestIncomingSmsMore(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = stateAlerts(7)
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("alerts CA"))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("More"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("NPS alerts for California, 6-7 of 7:\n\n6. PARK_6: HEADER_6\n7. PARK_7: HEADER_7\n\n"+alertsPageLastHint, twilioClient.lastMessage)
+
+	session, _, _ := st.Session(context.Background(), "+12407439754")
+	assert.Equal(7, session.Next)
+
+	w = httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("next"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`There are no more alerts for California. Text "read" followed by a number from 1 to 7 to read one in full.`, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsMoreSingleAlert(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = stateAlerts(1)
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("alerts CA"))
+	assert.Equal("Here is the most recent NPS California alert from PARK_1, published DATE_1:\n\nHEADER_1\n\nMESSAGE_1\n\nFor a full list of NPS California alerts, visit URL_1", twilioClient.lastMessage)
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("more"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`There are no more alerts for California. Text "read" followed by a number from 1 to 1 to read one in full.`, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsMoreNoSession(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("more"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(noSessionMessage, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsMoreExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withNPS(npsClient), withTwilio(twilioClient))
+	s.sessionIdleTimeout = 15 * time.Minute
+	npsClient.getAlertsResponse = stateAlerts(7)
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("alerts CA"))
+
+	later := s.clock().Add(16 * time.Minute)
+	s.now = func() time.Time { return later }
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("more"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(noSessionMessage, twilioClient.lastMessage)
+
+	_, ok, _ := st.Session(context.Background(), "+12407439754")
+	assert.False(ok)
+}
+
+func TestIncomingSmsMoreStoreError(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient), withSessions(failingStore{}))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("more"))
+
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+	assert.Equal(internalErrorMessage, twilioClient.lastMessage)
+}
+
+func TestIncomingSmsRead(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withStore(st), withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = stateAlerts(7)
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("alerts CA"))
+
+	// reading keeps the session alive
+	later := s.clock().Add(10 * time.Minute)
+	s.now = func() time.Time { return later }
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("read 6"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("Here is the most recent NPS California alert from PARK_6, published DATE_6:\n\nHEADER_6\n\nMESSAGE_6\n\nFor a full list of NPS California alerts, visit URL_6", twilioClient.lastMessage)
+
+	session, _, _ := st.Session(context.Background(), "+12407439754")
+	assert.Equal(later, session.UpdatedAt)
+	assert.Equal(5, session.Next)
+}
+
+func TestIncomingSmsReadOutOfRange(t *testing.T) {
+	assert := assert.New(t)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	npsClient.getAlertsResponse = stateAlerts(7)
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("alerts CA"))
+
+	for _, body := range []string{"read", "read 8", "read 0", "read three"} {
+		w := httptest.NewRecorder()
+		s.IncomingSmsHandler(w, smsRequest(body))
+
+		assert.Equal(http.StatusBadRequest, w.Result().StatusCode, body)
+		assert.Equal(`Text "read" followed by a number from 1 to 7, like "read 1".`, twilioClient.lastMessage, body)
+	}
+}
+
+func TestIncomingSmsReadNoSession(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("read 1"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(noSessionMessage, twilioClient.lastMessage)
+}
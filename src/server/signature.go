@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/twilio/twilio-go/client"
+	"go.uber.org/zap"
+)
+
+const twilioSignatureHeader = "X-Twilio-Signature"
+
+// requireTwilioSignature rejects requests without a valid X-Twilio-Signature
+// with 403 Forbidden. Twilio signs each webhook with an HMAC-SHA1 of the URL
+// it called and the POST parameters, keyed with the account's auth token, so
+// only Twilio can make us send texts.
+func (s *Server) requireTwilioSignature(next http.Handler) http.Handler {
+	validator := client.NewRequestValidator(s.twilioAuthToken)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := s.logger.With(zap.String("requestID", middleware.GetReqID(r.Context())))
+
+		signature := r.Header.Get(twilioSignatureHeader)
+		if signature == "" {
+			logger.Warn("rejected webhook without a twilio signature")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			logger.Warn("rejected webhook with a malformed body", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		params := make(map[string]string, len(r.PostForm))
+		for key, values := range r.PostForm {
+			if len(values) > 0 {
+				params[key] = values[0]
+			}
+		}
+
+		if !validator.Validate(s.webhookURL(r), params, signature) {
+			logger.Warn("rejected webhook with an invalid twilio signature")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webhookURL is the URL Twilio called to deliver r, which the signature
+// covers. Behind a proxy or tunnel the request does not know the public
+// address, so the configured webhook URL is used as the base when set.
+func (s *Server) webhookURL(r *http.Request) string {
+	base := s.twilioWebhookURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return strings.TrimSuffix(base, "/") + r.URL.RequestURI()
+}
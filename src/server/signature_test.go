@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testAuthToken = "12345"
+
+// sign computes the X-Twilio-Signature Twilio would send for a POST of form
+// to fullURL.
+func sign(fullURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	payload := fullURL
+	for _, key := range keys {
+		payload += key + form.Get(key)
+	}
+
+	mac := hmac.New(sha1.New, []byte(testAuthToken))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signedRequest(target string, form url.Values, signature string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if signature != "" {
+		r.Header.Set(twilioSignatureHeader, signature)
+	}
+	return r
+}
+
+func signatureServer(webhookURL string) (*Server, *bool) {
+	called := false
+	s := &Server{
+		logger:           zap.NewNop(),
+		twilioAuthToken:  testAuthToken,
+		twilioWebhookURL: webhookURL,
+	}
+	return s, &called
+}
+
+func serveSigned(s *Server, called *bool, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.requireTwilioSignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*called = true
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, r)
+	return w
+}
+
+func TestSignatureValid(t *testing.T) {
+	assert := assert.New(t)
+
+	s, called := signatureServer("https://alerts.example.com")
+	form := url.Values{"from": {"+15555555555"}, "body": {"alerts UT"}}
+
+	r := signedRequest("/incoming-sms", form, sign("https://alerts.example.com/incoming-sms", form))
+	w := serveSigned(s, called, r)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.True(*called)
+	assert.Equal("alerts UT", r.FormValue("body"))
+}
+
+func TestSignatureMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	s, called := signatureServer("https://alerts.example.com")
+	form := url.Values{"from": {"+15555555555"}, "body": {"alerts UT"}}
+
+	w := serveSigned(s, called, signedRequest("/incoming-sms", form, ""))
+
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.False(*called)
+}
+
+func TestSignatureTamperedBody(t *testing.T) {
+	assert := assert.New(t)
+
+	s, called := signatureServer("https://alerts.example.com")
+	form := url.Values{"from": {"+15555555555"}, "body": {"alerts UT"}}
+	signature := sign("https://alerts.example.com/incoming-sms", form)
+
+	form.Set("body", "alerts CA")
+	w := serveSigned(s, called, signedRequest("/incoming-sms", form, signature))
+
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.False(*called)
+}
+
+func TestSignatureWrongToken(t *testing.T) {
+	assert := assert.New(t)
+
+	s, called := signatureServer("https://alerts.example.com")
+	s.twilioAuthToken = "other"
+	form := url.Values{"from": {"+15555555555"}, "body": {"alerts UT"}}
+
+	w := serveSigned(s, called, signedRequest("/incoming-sms", form, sign("https://alerts.example.com/incoming-sms", form)))
+
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.False(*called)
+}
+
+func TestSignatureWrongURL(t *testing.T) {
+	assert := assert.New(t)
+
+	s, called := signatureServer("https://alerts.example.com")
+	form := url.Values{"from": {"+15555555555"}, "body": {"alerts UT"}}
+
+	w := serveSigned(s, called, signedRequest("/incoming-sms", form, sign("https://evil.example.com/incoming-sms", form)))
+
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.False(*called)
+}
+
+func TestSignatureRequestHost(t *testing.T) {
+	assert := assert.New(t)
+
+	s, called := signatureServer("")
+	form := url.Values{"from": {"+15555555555"}, "body": {"alerts UT"}}
+
+	r := signedRequest("http://alerts.example.com/incoming-sms?x=1", form, sign("https://alerts.example.com/incoming-sms?x=1", form))
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w := serveSigned(s, called, r)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.True(*called)
+}
+
+func TestWebhookURLTrailingSlash(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Server{twilioWebhookURL: "https://alerts.example.com/"}
+	r := httptest.NewRequest(http.MethodPost, "/incoming-sms", nil)
+
+	assert.Equal("https://alerts.example.com/incoming-sms", s.webhookURL(r))
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"go.uber.org/zap"
+)
+
+// logCacheStats logs how the NPS cache has answered alert queries every
+// interval until ctx is cancelled. The counts are totals since startup.
+func logCacheStats(ctx context.Context, cache *nps.CachingClient, interval time.Duration) {
+	logger := logging.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := cache.Stats()
+			logger.Info("nps cache stats",
+				zap.Uint64("hits", stats.Hits),
+				zap.Uint64("misses", stats.Misses),
+				zap.Uint64("stale", stats.Stale),
+			)
+		}
+	}
+}
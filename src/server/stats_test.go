@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogCacheStats(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := nps.NewCachingClient(&mockNpsClient{getAlertsResponse: []nps.AlertDetails{{ID: "1"}}}, time.Minute)
+	cache.GetAlerts(context.Background(), "CA", &nps.AlertOptions{})
+	cache.GetAlerts(context.Background(), "CA", &nps.AlertOptions{})
+
+	core, logs := observer.New(zap.InfoLevel)
+	ctx, cancel := context.WithCancel(logging.WithLogger(context.Background(), zap.New(core)))
+
+	done := make(chan struct{})
+	go func() {
+		logCacheStats(ctx, cache, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(func() bool { return logs.FilterMessage("nps cache stats").Len() > 0 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	fields := logs.FilterMessage("nps cache stats").All()[0].ContextMap()
+	assert.Equal(uint64(1), fields["hits"])
+	assert.Equal(uint64(1), fields["misses"])
+	assert.Equal(uint64(0), fields["stale"])
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"go.uber.org/zap"
+)
+
+const (
+	badSubscribeMessage      = `I'm sorry, I couldn't understand your message. Please text "subscribe {state}" or "subscribe {park}" to get new alerts as they're posted`
+	subscribedMessage        = "You're subscribed to new NPS alerts for %s. Text \"unsubscribe %s\" to stop."
+	alreadySubscribedMessage = "You're already subscribed to NPS alerts for %s."
+	unsubscribedMessage      = "You're unsubscribed from NPS alerts for %s."
+	notSubscribedMessage     = "You aren't subscribed to NPS alerts for %s. Text \"list\" to see your subscriptions."
+	listMessage              = "You're subscribed to new NPS alerts for:\n\n%s\n\nText \"unsubscribe\" followed by a state or park to stop."
+	noSubscriptionsMessage   = "You aren't subscribed to any NPS alerts. Text \"subscribe {state}\" or \"subscribe {park}\" to get new alerts as they're posted."
+)
+
+func (s *Server) subscribeHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	from := inboundMessageFromContext(ctx).From
+	target := strings.Join(args, " ")
+
+	if target == "" {
+		s.reply(w, r, from, tr(ctx, badSubscribeMessage), http.StatusBadRequest)
+		return
+	}
+
+	topic, name, err := s.resolveTopic(target)
+	if err != nil {
+		message, status := npsErrorReply(languageFromContext(ctx), err, target)
+		if s.reply(w, r, from, message, status) {
+			logger.Info(err.Error())
+		}
+		return
+	}
+
+	created, err := s.subscriptions.Subscribe(ctx, store.Subscription{
+		Phone:     from,
+		Topic:     topic,
+		Name:      name,
+		CreatedAt: s.clock(),
+	})
+	if err != nil {
+		logger.Error("failed to save subscription", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if !created {
+		s.reply(w, r, from, tr(ctx, alreadySubscribedMessage, name), http.StatusOK)
+		return
+	}
+
+	if s.reply(w, r, from, tr(ctx, subscribedMessage, name, topic.Code), http.StatusOK) {
+		logger.Info("subscribed", zap.Stringer("topic", topic))
+	}
+}
+
+func (s *Server) unsubscribeHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	from := inboundMessageFromContext(ctx).From
+	target := strings.Join(args, " ")
+
+	if target == "" {
+		s.reply(w, r, from, tr(ctx, badSubscribeMessage), http.StatusBadRequest)
+		return
+	}
+
+	topic, name, err := s.resolveTopic(target)
+	if err != nil {
+		message, status := npsErrorReply(languageFromContext(ctx), err, target)
+		if s.reply(w, r, from, message, status) {
+			logger.Info(err.Error())
+		}
+		return
+	}
+
+	removed, err := s.subscriptions.Unsubscribe(ctx, from, topic)
+	if err != nil {
+		logger.Error("failed to remove subscription", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if !removed {
+		s.reply(w, r, from, tr(ctx, notSubscribedMessage, name), http.StatusOK)
+		return
+	}
+
+	if s.reply(w, r, from, tr(ctx, unsubscribedMessage, name), http.StatusOK) {
+		logger.Info("unsubscribed", zap.Stringer("topic", topic))
+	}
+}
+
+func (s *Server) listHandler(w http.ResponseWriter, r *http.Request, args []string) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	from := inboundMessageFromContext(ctx).From
+
+	subs, err := s.subscriptions.Subscriptions(ctx, from)
+	if err != nil {
+		logger.Error("failed to list subscriptions", zap.Error(err))
+		s.reply(w, r, from, tr(ctx, internalErrorMessage), http.StatusInternalServerError)
+		return
+	}
+
+	if len(subs) == 0 {
+		s.reply(w, r, from, tr(ctx, noSubscriptionsMessage), http.StatusOK)
+		return
+	}
+
+	lines := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		lines = append(lines, fmt.Sprintf("%s (%s)", sub.Name, sub.Topic.Code))
+	}
+
+	s.reply(w, r, from, tr(ctx, listMessage, strings.Join(lines, "\n")), http.StatusOK)
+}
+
+// resolveTopic turns what a texter typed after "subscribe" or "unsubscribe"
+// into a topic and the name to show for it. A state code or name is a state,
+// anything else is a park code or name. Unknown targets return the same
+// errors as the NPS client so npsErrorReply can phrase them.
+func (s *Server) resolveTopic(target string) (store.Topic, string, error) {
+
+	if code, ok := nps.ResolveState(target); ok {
+		name, _ := nps.StateName(code)
+		return store.Topic{Kind: store.TopicState, Code: code}, name, nil
+	}
+
+	if len(target) == 2 {
+		code := strings.ToUpper(target)
+		name, ok := nps.StateName(code)
+		if !ok {
+			return store.Topic{}, "", fmt.Errorf("state code %s is not a valid state code: %w", code, nps.ErrInvalidState)
+		}
+		return store.Topic{Kind: store.TopicState, Code: code}, name, nil
+	}
+
+	park, suggestions, ok := s.parks.Resolve(target)
+	if !ok {
+		return store.Topic{}, "", &nps.UnknownParkError{Query: target, Suggestions: suggestions}
+	}
+	return store.Topic{Kind: store.TopicPark, Code: strings.ToLower(park.Code)}, park.Name, nil
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStore is a store whose every call fails.
+type failingStore struct{}
+
+func (failingStore) Subscribe(ctx context.Context, sub store.Subscription) (bool, error) {
+	return false, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) Unsubscribe(ctx context.Context, phone string, topic store.Topic) (bool, error) {
+	return false, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) Subscriptions(ctx context.Context, phone string) ([]store.Subscription, error) {
+	return nil, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) Topics(ctx context.Context) ([]store.Topic, error) {
+	return nil, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) Subscribers(ctx context.Context, topic store.Topic) ([]store.Subscription, error) {
+	return nil, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) OptOut(ctx context.Context, phone string) (bool, error) {
+	return false, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) OptIn(ctx context.Context, phone string) (bool, error) {
+	return false, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	return false, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) Preferences(ctx context.Context, phone string) (store.Preferences, error) {
+	return store.Preferences{}, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) SetPreferences(ctx context.Context, phone string, prefs store.Preferences) error {
+	return errors.New("TEST_STORE_ERR")
+}
+
+func TestIncomingSmsSubscribeState(t *testing.T) {
+	assert := assert.New(t)
+
+	subscriptions := store.NewMemory()
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withSubscriptions(subscriptions), withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("Subscribe ut"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`You're subscribed to new NPS alerts for Utah. Text "unsubscribe UT" to stop.`, mockTwilioClient.lastMessage)
+
+	subs, _ := subscriptions.Subscriptions(context.Background(), "+12407439754")
+	assert.Equal([]store.Subscription{
+		{
+			Phone:     "+12407439754",
+			Topic:     store.Topic{Kind: store.TopicState, Code: "UT"},
+			Name:      "Utah",
+			CreatedAt: time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC),
+		},
+	}, subs)
+}
+
+func TestIncomingSmsSubscribePark(t *testing.T) {
+	assert := assert.New(t)
+
+	subscriptions := store.NewMemory()
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withSubscriptions(subscriptions), withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe yosemite"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`You're subscribed to new NPS alerts for Yosemite. Text "unsubscribe yose" to stop.`, mockTwilioClient.lastMessage)
+
+	w = httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe yose"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("You're already subscribed to NPS alerts for Yosemite.", mockTwilioClient.lastMessage)
+}
+
+func TestIncomingSmsSubscribeInvalidState(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe MV"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Contains(mockTwilioClient.lastMessage, `"MV" isn't a state code I recognize`)
+}
+
+func TestIncomingSmsSubscribeUnknownPark(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe grand"))
+
+	assert.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	assert.Contains(mockTwilioClient.lastMessage, "Did you mean")
+}
+
+func TestIncomingSmsSubscribeStoreErr(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withSubscriptions(failingStore{}), withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe UT"))
+
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+	assert.Equal(internalErrorMessage, mockTwilioClient.lastMessage)
+}
+
+func TestIncomingSmsUnsubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	subscriptions := store.NewMemory()
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withSubscriptions(subscriptions), withTwilio(mockTwilioClient))
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("subscribe UT"))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("unsubscribe ut"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("You're unsubscribed from NPS alerts for Utah.", mockTwilioClient.lastMessage)
+
+	w = httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("unsubscribe UT"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`You aren't subscribed to NPS alerts for Utah. Text "list" to see your subscriptions.`, mockTwilioClient.lastMessage)
+}
+
+func TestIncomingSmsList(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("list"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(noSubscriptionsMessage, mockTwilioClient.lastMessage)
+
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("subscribe UT"))
+	s.IncomingSmsHandler(httptest.NewRecorder(), smsRequest("subscribe yellowstone"))
+
+	w = httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("LIST"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("You're subscribed to new NPS alerts for:\n\nYellowstone (yell)\nUtah (UT)\n\nText \"unsubscribe\" followed by a state or park to stop.", mockTwilioClient.lastMessage)
+}
+
+func TestIncomingSmsListStoreErr(t *testing.T) {
+	assert := assert.New(t)
+
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withSubscriptions(failingStore{}), withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("list"))
+
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestIncomingSmsSubscribeStateName(t *testing.T) {
+	assert := assert.New(t)
+
+	subscriptions := store.NewMemory()
+	mockTwilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withSubscriptions(subscriptions), withTwilio(mockTwilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe new mexico"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal(`You're subscribed to new NPS alerts for New Mexico. Text "unsubscribe NM" to stop.`, mockTwilioClient.lastMessage)
+}
+
+func (failingStore) Session(ctx context.Context, phone string) (store.Session, bool, error) {
+	return store.Session{}, false, errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) SetSession(ctx context.Context, phone string, session store.Session) error {
+	return errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) DeleteSession(ctx context.Context, phone string) error {
+	return errors.New("TEST_STORE_ERR")
+}
+
+func (failingStore) ExpireSessions(ctx context.Context, cutoff time.Time) (int, error) {
+	return 0, errors.New("TEST_STORE_ERR")
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+)
+
+// Reply templates. Each is kept in a file of the same name with a ".tmpl"
+// extension, both in the embedded defaults and in an override directory,
+// once for each language.
+const (
+	helpTemplate     = "help"
+	alertTemplate    = "alert"
+	newAlertTemplate = "new_alert"
+)
+
+const templateExt = ".tmpl"
+
+//go:embed templates/*.tmpl templates/*/*.tmpl
+var defaultTemplateFiles embed.FS
+
+// helpData is what the help template is rendered with.
+type helpData struct {
+	// Commands are in the order they should be listed.
+	Commands []helpCommand
+}
+
+type helpCommand struct {
+	Name    string
+	Aliases []string
+	Usage   []string
+}
+
+// alertData is what the alert templates are rendered with: the alert's
+// details, such as .FullParkName and .AlertMessage, and how it was asked for.
+type alertData struct {
+	nps.AlertDetails
+
+	// Published is when the alert was published, in the local time of its
+	// state, e.g. "Jun 7 at 10:55 AM PDT (3 hours ago)".
+	Published string
+
+	// Category is the lower-case category the alerts were narrowed to, e.g.
+	// "park closure", or empty.
+	Category string
+
+	// IsState is set when the alert was asked for by state rather than park.
+	IsState bool
+
+	// Subscription is who a new alert is being sent to. It is only set for
+	// the new_alert template.
+	Subscription store.Subscription
+}
+
+// templateSamples are rendered with each template when it is loaded, so a
+// template naming a field that does not exist fails at startup rather than
+// when a text is sent.
+var templateSamples = map[string][]interface{}{
+	helpTemplate: {
+		helpData{Commands: []helpCommand{{Name: "help", Aliases: []string{"info"}, Usage: []string{"Help: receive this help text"}}}},
+	},
+	alertTemplate: {
+		sampleAlertData(true),
+		sampleAlertData(false),
+	},
+	newAlertTemplate: {
+		sampleAlertData(false),
+	},
+}
+
+func sampleAlertData(isState bool) alertData {
+	return alertData{
+		AlertDetails: nps.AlertDetails{
+			ID:              "SAMPLE_ID",
+			ParkCode:        "yose",
+			FullStateName:   "California",
+			FullParkName:    "Yosemite",
+			Category:        "Park Closure",
+			RecentAlertDate: "2022-06-07 10:55:48.0",
+			RecentAlertTime: time.Date(2022, 6, 7, 17, 55, 48, 0, time.UTC),
+			StateCode:       "CA",
+			AlertHeader:     "Tioga Road is closed",
+			AlertMessage:    "Tioga Road is closed for the season.",
+			URL:             "https://www.nps.gov/yose/planyourvisit/conditions.htm",
+		},
+		Published: "Jun 7 at 10:55 AM PDT (3 hours ago)",
+		Category:  "park closure",
+		IsState:   isState,
+		Subscription: store.Subscription{
+			Phone: "+15555550100",
+			Topic: store.Topic{Kind: store.TopicPark, Code: "yose"},
+			Name:  "Yosemite",
+		},
+	}
+}
+
+// replyTemplates renders replies from text/template templates, keyed by
+// language and then name.
+type replyTemplates struct {
+	templates map[string]map[string]*template.Template
+}
+
+// defaultReplyTemplates are the embedded templates, used when the server has
+// not loaded its own.
+var defaultReplyTemplates = func() *replyTemplates {
+	t, err := loadTemplates("")
+	if err != nil {
+		panic(fmt.Sprintf("invalid default reply templates: %s", err))
+	}
+	return t
+}()
+
+// loadTemplates parses the embedded reply templates, replacing any that have
+// a file of the same name in dir, and checks that each renders. English
+// templates are at the top of dir and the others in a directory named for
+// their language, like "es/alert.tmpl". An empty dir uses the embedded
+// templates alone.
+func loadTemplates(dir string) (*replyTemplates, error) {
+
+	t := &replyTemplates{templates: map[string]map[string]*template.Template{}}
+
+	for _, lang := range i18n.Languages {
+		t.templates[lang] = map[string]*template.Template{}
+
+		for name := range templateSamples {
+			file := filepath.Join(templateLanguageDir(lang), name+templateExt)
+
+			content, err := defaultTemplateFiles.ReadFile(filepath.ToSlash(filepath.Join("templates", file)))
+			if err != nil {
+				return nil, err
+			}
+
+			if dir != "" {
+				override, err := ioutil.ReadFile(filepath.Join(dir, file))
+				switch {
+				case err == nil:
+					content = override
+				case !errors.Is(err, os.ErrNotExist):
+					return nil, err
+				}
+			}
+
+			tmpl, err := template.New(file).Parse(string(content))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, sample := range templateSamples[name] {
+				if err := tmpl.Execute(ioutil.Discard, sample); err != nil {
+					return nil, err
+				}
+			}
+
+			t.templates[lang][name] = tmpl
+		}
+	}
+
+	if dir == "" {
+		return t, nil
+	}
+
+	// a misspelled file name would otherwise be silently ignored
+	if err := checkTemplateNames(dir); err != nil {
+		return nil, err
+	}
+	for _, lang := range i18n.Languages[1:] {
+		err := checkTemplateNames(filepath.Join(dir, templateLanguageDir(lang)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// templateLanguageDir is the directory, relative to the templates, that
+// holds the templates for lang.
+func templateLanguageDir(lang string) string {
+	if lang == i18n.English {
+		return ""
+	}
+	return lang
+}
+
+// checkTemplateNames returns an error when dir has a template file that is
+// not named for a reply.
+func checkTemplateNames(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), templateExt)
+		if f.IsDir() || name == f.Name() {
+			continue
+		}
+		if _, ok := templateSamples[name]; !ok {
+			return fmt.Errorf("unknown template %q in %s", f.Name(), dir)
+		}
+	}
+	return nil
+}
+
+// render executes the template called name in lang with data, falling back
+// to English for a language without templates. Leading and trailing space
+// is trimmed so template files can end in a newline.
+func (t *replyTemplates) render(lang, name string, data interface{}) (string, error) {
+	templates, ok := t.templates[lang]
+	if !ok {
+		templates = t.templates[i18n.English]
+	}
+
+	var b strings.Builder
+	if err := templates[name].Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// replies returns the server's reply templates, which tests that build a
+// Server directly leave as the defaults.
+func (s *Server) replies() *replyTemplates {
+	if s.templates != nil {
+		return s.templates
+	}
+	return defaultReplyTemplates
+}
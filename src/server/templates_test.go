@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/i18n"
+	"github.com/WilliamDeBruin/nps_alerts/src/nps"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTemplate(t *testing.T, dir, file, content string) {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTemplatesDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	templates, err := loadTemplates("")
+
+	assert.Nil(err)
+	assert.Len(templates.templates, 2)
+	assert.Len(templates.templates[i18n.English], 3)
+	assert.Len(templates.templates[i18n.Spanish], 3)
+}
+
+func TestLoadTemplatesOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "alert.tmpl", "{{.FullParkName}}: {{.AlertHeader}}{{with .URL}} {{.}}{{end}}\n")
+	writeTemplate(t, dir, "README.md", "not a template")
+
+	templates, err := loadTemplates(dir)
+	assert.Nil(err)
+
+	message, err := templates.render(i18n.English, alertTemplate, sampleAlertData(true))
+	assert.Nil(err)
+	assert.Equal("Yosemite: Tioga Road is closed https://www.nps.gov/yose/planyourvisit/conditions.htm", message)
+
+	// templates without an override keep the built-in text
+	message, err = templates.render(i18n.English, newAlertTemplate, sampleAlertData(false))
+	assert.Nil(err)
+	assert.Contains(message, "New NPS alert for Yosemite")
+}
+
+func TestLoadTemplatesParseError(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "help.tmpl", "{{range .Commands}")
+
+	_, err := loadTemplates(dir)
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "help.tmpl")
+}
+
+func TestLoadTemplatesUnknownField(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "alert.tmpl", "{{if .IsState}}{{.FullStateName}}{{else}}{{.ParkName}}{{end}}")
+
+	_, err := loadTemplates(dir)
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "ParkName")
+}
+
+func TestLoadTemplatesUnknownFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "alerts.tmpl", "{{.AlertHeader}}")
+
+	_, err := loadTemplates(dir)
+
+	assert.EqualError(err, `unknown template "alerts.tmpl" in `+dir)
+}
+
+func TestLoadTemplatesMissingDir(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := loadTemplates(filepath.Join(t.TempDir(), "missing"))
+
+	assert.NotNil(err)
+}
+
+func TestIncomingSmsAlertCustomTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, "alert.tmpl", "{{.FullStateName}} {{.Category}}: {{.AlertHeader}}")
+
+	templates, err := loadTemplates(dir)
+	assert.Nil(err)
+
+	npsClient := &mockNpsClient{}
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withNPS(npsClient), withTwilio(twilioClient))
+	s.templates = templates
+	npsClient.getAlertsResponse = []nps.AlertDetails{{FullStateName: "California", AlertHeader: "TEST_HEADER"}}
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA closures"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Equal("California park closure: TEST_HEADER", twilioClient.lastMessage)
+}
+
+func TestLoadTemplatesSpanishOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, i18n.Spanish), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTemplate(t, dir, "es/alert.tmpl", "{{.FullParkName}}: {{.AlertHeader}}")
+
+	templates, err := loadTemplates(dir)
+	assert.Nil(err)
+
+	message, err := templates.render(i18n.Spanish, alertTemplate, sampleAlertData(false))
+	assert.Nil(err)
+	assert.Equal("Yosemite: Tioga Road is closed", message)
+
+	// the English template is untouched
+	message, err = templates.render(i18n.English, alertTemplate, sampleAlertData(false))
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(message, "Here is the most recent NPS park closure alert"))
+}
+
+func TestLoadTemplatesUnknownSpanishFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, i18n.Spanish), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTemplate(t, dir, "es/alerta.tmpl", "{{.AlertHeader}}")
+
+	_, err := loadTemplates(dir)
+
+	assert.EqualError(err, `unknown template "alerta.tmpl" in `+filepath.Join(dir, i18n.Spanish))
+}
+
+func TestRenderUnknownLanguage(t *testing.T) {
+	assert := assert.New(t)
+
+	message, err := defaultReplyTemplates.render("fr", newAlertTemplate, sampleAlertData(false))
+
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(message, "New NPS alert for Yosemite"))
+}
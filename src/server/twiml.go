@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
+	"go.uber.org/zap"
+)
+
+// twimlResponse is a TwiML MessagingResponse. Twilio sends each Message
+// back to the texter when it is returned from the incoming message webhook.
+type twimlResponse struct {
+	XMLName  xml.Name       `xml:"Response"`
+	Messages []twimlMessage `xml:"Message"`
+}
+
+type twimlMessage struct {
+	Body string `xml:",chardata"`
+}
+
+// replyTwiML answers the webhook with message as TwiML rather than sending it
+// through the REST API. Twilio only delivers TwiML from a 2xx response, so
+// the status of the request is always 200; replies to bad commands are still
+// logged by their handlers. Senders who have opted out get an empty response.
+func (s *Server) replyTwiML(w http.ResponseWriter, r *http.Request, to, message string) bool {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	response := twimlResponse{}
+	sent := true
+
+	optedOut := false
+	if s.optOuts != nil {
+		var err error
+		optedOut, err = s.optOuts.IsOptedOut(ctx, to)
+		if err != nil {
+			logger.Error("cannot check opt-outs", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return false
+		}
+	}
+
+	if optedOut {
+		// nothing can be sent until the texter opts back in with START
+		logger.Info("not replying to opted out number")
+		sent = false
+	} else {
+		response.Messages = append(response.Messages, twimlMessage{Body: message})
+	}
+
+	content, err := xml.Marshal(response)
+	if err != nil {
+		logger.Error("cannot render twiml reply", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(append([]byte(xml.Header), content...)); err != nil {
+		logger.Error("cannot write twiml reply", zap.Error(err))
+		return false
+	}
+
+	return sent
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WilliamDeBruin/nps_alerts/src/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwiMLReply(t *testing.T) {
+	assert := assert.New(t)
+
+	twilioClient := &mockTwilioClient{}
+	s := newTestServer(t, withOptOuts(store.NewMemory()), withTwiML(), withTwilio(twilioClient))
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("help"))
+
+	res := w.Result()
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("text/xml; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Contains(string(body), "<Response><Message>Welcome to NPS alerts!")
+	assert.Empty(twilioClient.lastMessage)
+}
+
+// ctxOptOuts fails opt-out checks on a context that is done, like a store
+// behind a network connection would.
+type ctxOptOuts struct {
+	*store.Memory
+}
+
+func (s ctxOptOuts) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.Memory.IsOptedOut(ctx, phone)
+}
+
+func TestTwiMLReplyAfterSlowLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t, withOptOuts(ctxOptOuts{store.NewMemory()}), withNPS(&mockNpsClient{slow: true}), withTwiML())
+	s.requestTimeout = 20 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("alerts CA"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Contains(w.Body.String(), "<Message>"+unavailableMessage+"</Message>")
+}
+
+func TestTwiMLReplyEscapes(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t, withOptOuts(store.NewMemory()), withTwiML())
+
+	w := httptest.NewRecorder()
+	s.reply(w, smsRequest("help"), "+12407439754", `<b>"Tom & Jerry"</b>`, http.StatusOK)
+
+	assert.Equal(`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<Response><Message>&lt;b&gt;&#34;Tom &amp; Jerry&#34;&lt;/b&gt;</Message></Response>`, w.Body.String())
+}
+
+func TestTwiMLReplyBadCommandIsOK(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t, withOptOuts(store.NewMemory()), withTwiML())
+
+	w := httptest.NewRecorder()
+	s.IncomingSmsHandler(w, smsRequest("subscribe qqqqqqqqqq"))
+
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Contains(w.Body.String(), "<Message>I&#39;m sorry, I couldn&#39;t find")
+}
+
+func TestTwiMLReplyOptedOut(t *testing.T) {
+	assert := assert.New(t)
+
+	st := store.NewMemory()
+	s := newTestServer(t, withStore(st), withOptOuts(st), withTwiML())
+	_, _ = st.OptOut(context.Background(), "+12407439754")
+
+	w := httptest.NewRecorder()
+	sent := s.reply(w, smsRequest("help"), "+12407439754", testHelpMessage(t), http.StatusOK)
+
+	assert.False(sent)
+	assert.Equal(http.StatusOK, w.Result().StatusCode)
+	assert.Contains(w.Body.String(), "<Response></Response>")
+}
+
+func TestTwiMLReplyOptOutCheckFail(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newTestServer(t, withOptOuts(&failingStore{}), withTwiML())
+
+	w := httptest.NewRecorder()
+	sent := s.reply(w, smsRequest("help"), "+12407439754", testHelpMessage(t), http.StatusOK)
+
+	assert.False(sent)
+	assert.Equal(http.StatusInternalServerError, w.Result().StatusCode)
+}
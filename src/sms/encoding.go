@@ -0,0 +1,154 @@
+// Package sms measures texts the way carriers bill them and builds replies
+// that fit in a budget of SMS segments.
+package sms
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Encoding is the character set a text is sent in.
+type Encoding int
+
+const (
+	// GSM7 packs each character into 7 bits, but only covers the GSM 03.38
+	// alphabet.
+	GSM7 Encoding = iota
+	// UCS2 sends any character in 16 bits. A single character outside the
+	// GSM alphabet, such as an emoji or a curly quote, sends the whole text
+	// as UCS-2 and more than doubles its segments.
+	UCS2
+)
+
+func (e Encoding) String() string {
+	if e == UCS2 {
+		return "UCS-2"
+	}
+	return "GSM-7"
+}
+
+// Segment sizes from 3GPP TS 23.040. A text that does not fit in one segment
+// is split into parts that each lose room to a concatenation header.
+const (
+	gsmSingleSegment  = 160
+	gsmMultiSegment   = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// gsmBasic is the GSM 03.38 default alphabet, each character one septet.
+const gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsmExtension characters are sent as an escape followed by a second septet.
+const gsmExtension = "\f^{}\\[~]|€"
+
+var septets = func() map[rune]int {
+	widths := map[rune]int{}
+	for _, r := range gsmBasic {
+		widths[r] = 1
+	}
+	for _, r := range gsmExtension {
+		widths[r] = 2
+	}
+	return widths
+}()
+
+// Stats describes how a text is sent.
+type Stats struct {
+	Encoding Encoding
+	// Units is the length of the text in septets for GSM-7 and in UTF-16
+	// code units for UCS-2.
+	Units int
+	// Segments is the number of SMS the text is split into, each billed
+	// separately.
+	Segments int
+}
+
+// Measure returns the encoding, length and segment count of text.
+func Measure(text string) Stats {
+	if text == "" {
+		return Stats{Encoding: GSM7}
+	}
+
+	widths, encoding := unitWidths(text)
+
+	single, multi := gsmSingleSegment, gsmMultiSegment
+	if encoding == UCS2 {
+		single, multi = ucs2SingleSegment, ucs2MultiSegment
+	}
+
+	units := 0
+	for _, w := range widths {
+		units += w
+	}
+	if units <= single {
+		return Stats{Encoding: encoding, Units: units, Segments: 1}
+	}
+
+	// an escape pair or a surrogate pair is never split across segments
+	segments, used := 1, 0
+	for _, w := range widths {
+		if used+w > multi {
+			segments++
+			used = 0
+		}
+		used += w
+	}
+
+	return Stats{Encoding: encoding, Units: units, Segments: segments}
+}
+
+// IsGSM reports whether text can be sent as GSM-7.
+func IsGSM(text string) bool {
+	for _, r := range text {
+		if septets[r] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// unitWidths returns the width of each character of text in the encoding it
+// will be sent in.
+func unitWidths(text string) ([]int, Encoding) {
+	widths := make([]int, 0, len(text))
+
+	if IsGSM(text) {
+		for _, r := range text {
+			widths = append(widths, septets[r])
+		}
+		return widths, GSM7
+	}
+
+	for _, r := range text {
+		widths = append(widths, len(utf16.Encode([]rune{r})))
+	}
+	return widths, UCS2
+}
+
+// transliterations replace common characters outside the GSM alphabet with
+// ones inside it. NPS copy is full of curly quotes and dashes pasted from
+// word processors, and each one would otherwise send the text as UCS-2.
+var transliterations = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'", "`", "'", "´", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`, "«", `"`, "»", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-", "−", "-",
+	"…", "...", "•", "-", "·", "-",
+	"\u00a0", " ", "\u2002", " ", "\u2003", " ", "\u2009", " ", "\u202f", " ", "\t", " ",
+	"\u200b", "", "\u200d", "", "\ufeff", "",
+	"™", "(TM)", "®", "(R)", "©", "(C)", "°", "", "½", "1/2", "¼", "1/4", "¾", "3/4",
+	"á", "a", "â", "a", "ã", "a", "ā", "a", "Á", "A", "À", "A", "Â", "A", "Ã", "A",
+	"ê", "e", "ë", "e", "ē", "e", "È", "E", "Ê", "E", "Ë", "E",
+	"í", "i", "î", "i", "ï", "i", "ī", "i", "Í", "I", "Ì", "I", "Î", "I", "Ï", "I",
+	"ó", "o", "ô", "o", "õ", "o", "ō", "o", "Ó", "O", "Ò", "O", "Ô", "O", "Õ", "O",
+	"ú", "u", "û", "u", "ū", "u", "Ú", "U", "Ù", "U", "Û", "U",
+	"ý", "y", "ÿ", "y", "Ý", "Y", "ç", "c", "ʻ", "'",
+)
+
+// Transliterate replaces common characters that are not in the GSM alphabet,
+// such as curly quotes, dashes and accented vowels, with ones that are.
+// Characters without a stand-in, like emoji, are left alone.
+func Transliterate(text string) string {
+	return transliterations.Replace(text)
+}
@@ -0,0 +1,88 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasureEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Stats{Encoding: GSM7}, Measure(""))
+}
+
+func TestMeasureGSMSingle(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Stats{Encoding: GSM7, Units: 160, Segments: 1}, Measure(strings.Repeat("a", 160)))
+}
+
+func TestMeasureGSMMulti(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Stats{Encoding: GSM7, Units: 161, Segments: 2}, Measure(strings.Repeat("a", 161)))
+	assert.Equal(Stats{Encoding: GSM7, Units: 306, Segments: 2}, Measure(strings.Repeat("a", 306)))
+	assert.Equal(Stats{Encoding: GSM7, Units: 307, Segments: 3}, Measure(strings.Repeat("a", 307)))
+}
+
+func TestMeasureGSMExtension(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Stats{Encoding: GSM7, Units: 160, Segments: 1}, Measure(strings.Repeat("a", 158)+"€"))
+
+	// the escape pair at the end of the first segment moves to the second
+	// rather than being split, which pushes a third segment
+	text := strings.Repeat("a", 152) + "[" + strings.Repeat("a", 152)
+	assert.Equal(Stats{Encoding: GSM7, Units: 306, Segments: 3}, Measure(text))
+}
+
+func TestMeasureUCS2(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Stats{Encoding: UCS2, Units: 70, Segments: 1}, Measure(strings.Repeat("a", 69)+"’"))
+	assert.Equal(Stats{Encoding: UCS2, Units: 71, Segments: 2}, Measure(strings.Repeat("a", 70)+"’"))
+}
+
+func TestMeasureUCS2Surrogates(t *testing.T) {
+	assert := assert.New(t)
+
+	// emoji outside the basic multilingual plane take two code units
+	assert.Equal(Stats{Encoding: UCS2, Units: 70, Segments: 1}, Measure(strings.Repeat("a", 68)+"🏕"))
+
+	// a surrogate pair is not split, so it starts the second segment and
+	// pushes the text into a third
+	text := strings.Repeat("a", 66) + "🏕" + strings.Repeat("a", 66)
+	assert.Equal(Stats{Encoding: UCS2, Units: 134, Segments: 3}, Measure(text))
+}
+
+func TestEncodingString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("GSM-7", GSM7.String())
+	assert.Equal("UCS-2", UCS2.String())
+}
+
+func TestIsGSM(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsGSM("¡Señor! ¿Qué? {€}"))
+	assert.False(IsGSM("It’s closed"))
+	assert.False(IsGSM("🏕"))
+}
+
+func TestTransliterate(t *testing.T) {
+	assert := assert.New(t)
+
+	text := Transliterate("“Tioga Road” isn’t open — it’s closed… Café, Haleakalā ™")
+
+	assert.Equal(`"Tioga Road" isn't open - it's closed... Café, Haleakala (TM)`, text)
+	assert.True(IsGSM(text))
+}
+
+func TestTransliterateKeepsEmoji(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Campsites full 🏕", Transliterate("Campsites full 🏕"))
+}
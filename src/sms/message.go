@@ -0,0 +1,110 @@
+package sms
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	ellipsis = "..."
+
+	// minTruncatedRunes is the least of a shortened text worth keeping in
+	// place of optional content, such as a link.
+	minTruncatedRunes = 40
+)
+
+// Fit renders a text around one part that may be shortened, such as an
+// alert's description, so that it fits into maxSegments segments. render is
+// given text, transliterated and possibly shortened, and whether optional
+// content like a link should be included; its result is transliterated too.
+// The optional content is left out only when keeping it would cut text below
+// a useful length. Zero or less means no limit.
+func Fit(maxSegments int, text string, render func(text string, withOptional bool) (string, error)) (string, error) {
+
+	var renderErr error
+	try := func(text string, withOptional bool) (string, bool) {
+		rendered, err := render(text, withOptional)
+		if err != nil && renderErr == nil {
+			renderErr = err
+		}
+		rendered = Transliterate(rendered)
+		return rendered, maxSegments <= 0 || Measure(rendered).Segments <= maxSegments
+	}
+
+	text = Transliterate(text)
+	original := []rune(text)
+
+	rendered, ok := try(text, true)
+	if ok || renderErr != nil {
+		return rendered, renderErr
+	}
+
+	shortened := longest(original, func(text string) bool {
+		_, ok := try(text, true)
+		return ok
+	})
+	rendered, ok = try(shortened, true)
+	if ok && len([]rune(shortened)) >= minTruncatedRunes {
+		return rendered, renderErr
+	}
+
+	shortened = longest(original, func(text string) bool {
+		_, ok := try(text, false)
+		return ok
+	})
+	rendered, _ = try(shortened, false)
+	return rendered, renderErr
+}
+
+// longest returns the longest shortening of text that fits, found by
+// bisecting its length.
+func longest(text []rune, fits func(string) bool) string {
+	lo, hi := 0, len(text)
+	for lo < hi {
+		n := (lo + hi + 1) / 2
+		if fits(shorten(text, n)) {
+			lo = n
+		} else {
+			hi = n - 1
+		}
+	}
+	return shorten(text, lo)
+}
+
+// Shorten returns at most n characters of text, cut at a word boundary and
+// ending in "..." when anything was removed.
+func Shorten(text string, n int) string {
+	return shorten([]rune(text), n)
+}
+
+// shorten returns at most n runes of text, cut at a word boundary and ending
+// in an ellipsis when anything was removed.
+func shorten(text []rune, n int) string {
+	if n >= len(text) {
+		return string(text)
+	}
+
+	keep := n - len(ellipsis)
+	if keep <= 0 {
+		return ""
+	}
+
+	cut := text[:keep]
+
+	// back up to the end of the last whole word, unless that loses more
+	// than half of what fits
+	for i := len(cut); i > keep/2; i-- {
+		if unicode.IsSpace(cut[i-1]) || unicode.IsSpace(text[i]) {
+			cut = cut[:i]
+			break
+		}
+	}
+
+	trimmed := strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	if trimmed == "" {
+		return ""
+	}
+	return trimmed + ellipsis
+}
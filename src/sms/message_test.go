@@ -0,0 +1,85 @@
+package sms
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDescription = "Tioga Road is closed for the season between Crane Flat and Tuolumne Meadows. " +
+	"Plan to use Highway 120 from the west and Highway 140 from Mariposa instead, and check conditions before you go."
+
+func TestShorten(t *testing.T) {
+	assert := assert.New(t)
+
+	text := []rune("Tioga Road is closed")
+
+	assert.Equal("Tioga Road is closed", shorten(text, 20))
+	assert.Equal("Tioga Road is...", shorten(text, 18))
+	assert.Equal("Tioga...", shorten(text, 10))
+	assert.Equal("", shorten(text, 3))
+}
+
+func TestShortenString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Tioga Road is...", Shorten("Tioga Road is closed", 18))
+	assert.Equal("Tioga Road is closed", Shorten("Tioga Road is closed", 50))
+}
+
+func testRender(intro, link string) func(string, bool) (string, error) {
+	return func(text string, withLink bool) (string, error) {
+		if withLink {
+			return intro + text + link, nil
+		}
+		return intro + text, nil
+	}
+}
+
+func TestFitFits(t *testing.T) {
+	assert := assert.New(t)
+
+	text, err := Fit(1, "Tioga Road is “closed”", testRender("Alert: ", " https://nps.gov/yose"))
+
+	assert.Nil(err)
+	assert.Equal(`Alert: Tioga Road is "closed" https://nps.gov/yose`, text)
+}
+
+func TestFitTruncatesKeepingLink(t *testing.T) {
+	assert := assert.New(t)
+
+	intro := strings.Repeat("i", 100)
+	link := "\n\nhttps://www.nps.gov/yose/planyourvisit/conditions.htm"
+
+	text, err := Fit(2, testDescription, testRender(intro, link))
+
+	assert.Nil(err)
+	assert.True(strings.HasSuffix(text, "..."+link))
+	assert.LessOrEqual(Measure(text).Segments, 2)
+}
+
+func TestFitDropsLinkWhenNoRoom(t *testing.T) {
+	assert := assert.New(t)
+
+	intro := strings.Repeat("i", 120)
+	link := " https://www.nps.gov/yose/planyourvisit/conditions.htm"
+
+	text, err := Fit(1, testDescription, testRender(intro, link))
+
+	assert.Nil(err)
+	assert.NotContains(text, "https://")
+	assert.True(strings.HasPrefix(text, intro+"Tioga"))
+	assert.Equal(1, Measure(text).Segments)
+}
+
+func TestFitRenderError(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Fit(1, testDescription, func(string, bool) (string, error) {
+		return "", errors.New("TEST_RENDER_ERR")
+	})
+
+	assert.EqualError(err, "TEST_RENDER_ERR")
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// document is the layout of the file written by File.
+type document struct {
+	Subscriptions []Subscription     `json:"subscriptions"`
+	Seen          []seenAlerts       `json:"seen"`
+	OptOuts       []string           `json:"optOuts"`
+	Preferences   []phonePreferences `json:"preferences"`
+}
+
+type seenAlerts struct {
+	Topic Topic    `json:"topic"`
+	IDs   []string `json:"ids"`
+}
+
+type phonePreferences struct {
+	Phone string `json:"phone"`
+	Preferences
+}
+
+// File is a Store backed by a JSON file. Everything is held in memory and the
+// whole file is rewritten whenever something worth keeping changes, which is
+// plenty for the number of texters a single Twilio number serves. Sessions
+// only live for minutes, so they are kept in memory alone and lost on
+// restart.
+type File struct {
+	path string
+
+	// mu serializes changes so the file is written in the same order the
+	// changes were made
+	mu     sync.Mutex
+	memory *Memory
+}
+
+// NewFile returns a File store at path, loading anything already saved
+// there.
+func NewFile(path string) (*File, error) {
+
+	f := &File{path: path, memory: NewMemory()}
+
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := document{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	for _, sub := range doc.Subscriptions {
+		_, _ = f.memory.Subscribe(ctx, sub)
+	}
+	for _, seen := range doc.Seen {
+		_ = f.memory.SetSeenAlerts(ctx, seen.Topic, seen.IDs)
+	}
+	for _, phone := range doc.OptOuts {
+		_, _ = f.memory.OptOut(ctx, phone)
+	}
+	for _, prefs := range doc.Preferences {
+		_ = f.memory.SetPreferences(ctx, prefs.Phone, prefs.Preferences)
+	}
+
+	return f, nil
+}
+
+func (f *File) Subscribe(ctx context.Context, sub Subscription) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	created, err := f.memory.Subscribe(ctx, sub)
+	if err != nil || !created {
+		return created, err
+	}
+
+	if err := f.save(); err != nil {
+		_, _ = f.memory.Unsubscribe(ctx, sub.Phone, sub.Topic)
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) Unsubscribe(ctx context.Context, phone string, topic Topic) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	subs, err := f.memory.Subscriptions(ctx, phone)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := f.memory.Unsubscribe(ctx, phone, topic)
+	if err != nil || !removed {
+		return removed, err
+	}
+
+	if err := f.save(); err != nil {
+		for _, sub := range subs {
+			if sub.Topic == topic {
+				_, _ = f.memory.Subscribe(ctx, sub)
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) Subscriptions(ctx context.Context, phone string) ([]Subscription, error) {
+	return f.memory.Subscriptions(ctx, phone)
+}
+
+func (f *File) Topics(ctx context.Context) ([]Topic, error) {
+	return f.memory.Topics(ctx)
+}
+
+func (f *File) Subscribers(ctx context.Context, topic Topic) ([]Subscription, error) {
+	return f.memory.Subscribers(ctx, topic)
+}
+
+func (f *File) SeenAlerts(ctx context.Context, topic Topic) ([]string, bool, error) {
+	return f.memory.SeenAlerts(ctx, topic)
+}
+
+func (f *File) SetSeenAlerts(ctx context.Context, topic Topic, ids []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, hadPrevious, err := f.memory.SeenAlerts(ctx, topic)
+	if err != nil {
+		return err
+	}
+	if hadPrevious && sameIDs(previous, ids) {
+		// most polls find nothing new, so there is nothing to write
+		return nil
+	}
+
+	if err := f.memory.SetSeenAlerts(ctx, topic, ids); err != nil {
+		return err
+	}
+
+	if err := f.save(); err != nil {
+		if hadPrevious {
+			_ = f.memory.SetSeenAlerts(ctx, topic, previous)
+		} else {
+			f.memory.forgetSeen(topic)
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *File) OptOut(ctx context.Context, phone string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	added, err := f.memory.OptOut(ctx, phone)
+	if err != nil || !added {
+		return added, err
+	}
+
+	if err := f.save(); err != nil {
+		_, _ = f.memory.OptIn(ctx, phone)
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) OptIn(ctx context.Context, phone string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	removed, err := f.memory.OptIn(ctx, phone)
+	if err != nil || !removed {
+		return removed, err
+	}
+
+	if err := f.save(); err != nil {
+		_, _ = f.memory.OptOut(ctx, phone)
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	return f.memory.IsOptedOut(ctx, phone)
+}
+
+func (f *File) Preferences(ctx context.Context, phone string) (Preferences, error) {
+	return f.memory.Preferences(ctx, phone)
+}
+
+func (f *File) SetPreferences(ctx context.Context, phone string, prefs Preferences) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, err := f.memory.Preferences(ctx, phone)
+	if err != nil || previous == prefs {
+		return err
+	}
+
+	if err := f.memory.SetPreferences(ctx, phone, prefs); err != nil {
+		return err
+	}
+
+	if err := f.save(); err != nil {
+		_ = f.memory.SetPreferences(ctx, phone, previous)
+		return err
+	}
+	return nil
+}
+
+func (f *File) Session(ctx context.Context, phone string) (Session, bool, error) {
+	return f.memory.Session(ctx, phone)
+}
+
+func (f *File) SetSession(ctx context.Context, phone string, session Session) error {
+	return f.memory.SetSession(ctx, phone, session)
+}
+
+func (f *File) DeleteSession(ctx context.Context, phone string) error {
+	return f.memory.DeleteSession(ctx, phone)
+}
+
+func (f *File) ExpireSessions(ctx context.Context, cutoff time.Time) (int, error) {
+	return f.memory.ExpireSessions(ctx, cutoff)
+}
+
+func (f *File) Close() error {
+	return nil
+}
+
+// save writes to a temporary file first so a crash cannot leave a truncated
+// store behind.
+func (f *File) save() error {
+
+	content, err := json.MarshalIndent(document{
+		Subscriptions: f.memory.all(),
+		Seen:          f.memory.allSeen(),
+		OptOuts:       f.memory.allOptOuts(),
+		Preferences:   f.memory.allPreferences(),
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// sameIDs reports whether a and b hold the same alert IDs, in any order.
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[string]int, len(a))
+	for _, id := range a {
+		count[id]++
+	}
+	for _, id := range b {
+		if count[id] == 0 {
+			return false
+		}
+		count[id]--
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilePersists(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+
+	f, err := NewFile(path)
+	assert.Nil(err)
+
+	created := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	_, _ = f.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: created})
+	_, _ = f.Subscribe(ctx, Subscription{Phone: "+1555", Topic: yosemite, Name: "Yosemite", CreatedAt: created.Add(time.Hour)})
+	_, _ = f.Unsubscribe(ctx, "+1555", yosemite)
+	_ = f.SetSeenAlerts(ctx, utah, []string{"1", "2"})
+	_, _ = f.OptOut(ctx, "+1666")
+	_ = f.SetPreferences(ctx, "+1555", Preferences{HomeState: "UT", Language: "es"})
+	_ = f.SetSession(ctx, "+1555", testSession(created))
+	_ = f.SetSession(ctx, "+1666", testSession(created))
+	_ = f.DeleteSession(ctx, "+1666")
+
+	reloaded, err := NewFile(path)
+	assert.Nil(err)
+
+	subs, err := reloaded.Subscriptions(ctx, "+1555")
+
+	assert.Nil(err)
+	assert.Equal([]Subscription{
+		{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: created},
+	}, subs)
+
+	seen, ok, err := reloaded.SeenAlerts(ctx, utah)
+
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal([]string{"1", "2"}, seen)
+
+	optedOut, _ := reloaded.IsOptedOut(ctx, "+1666")
+	assert.True(optedOut)
+
+	prefs, _ := reloaded.Preferences(ctx, "+1555")
+	assert.Equal(Preferences{HomeState: "UT", Language: "es"}, prefs)
+
+	// sessions are short lived and only kept in memory
+	_, ok, _ = reloaded.Session(ctx, "+1555")
+	assert.False(ok)
+}
+
+func TestFileSkipsUnchangedWrites(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+
+	f, err := NewFile(path)
+	assert.Nil(err)
+
+	_ = f.SetSeenAlerts(ctx, utah, []string{"1", "2"})
+	_ = f.SetPreferences(ctx, "+1555", Preferences{HomeState: "UT"})
+	_ = os.Remove(path)
+
+	assert.Nil(f.SetSeenAlerts(ctx, utah, []string{"2", "1"}))
+	assert.Nil(f.SetPreferences(ctx, "+1555", Preferences{HomeState: "UT"}))
+	assert.Nil(f.SetSession(ctx, "+1555", testSession(time.Now())))
+
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err))
+
+	assert.Nil(f.SetSeenAlerts(ctx, utah, []string{"1", "2", "3"}))
+
+	_, err = os.Stat(path)
+	assert.Nil(err)
+}
+
+func TestFileCorrupt(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	_ = os.WriteFile(path, []byte("{not json"), 0o644)
+
+	f, err := NewFile(path)
+
+	assert.Nil(f)
+	assert.NotNil(err)
+}
+
+func TestFileSaveFailureRollsBack(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	// the directory does not exist, so the file cannot be written
+	f, err := NewFile(filepath.Join(t.TempDir(), "missing", "subscriptions.json"))
+	assert.Nil(err)
+
+	created, err := f.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	assert.False(created)
+	assert.NotNil(err)
+
+	subs, _ := f.Subscriptions(ctx, "+1555")
+	assert.Empty(subs)
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store that keeps everything in memory. Nothing survives a
+// restart, which makes it the store for tests and local runs.
+type Memory struct {
+	mu sync.RWMutex
+
+	// subscriptions is keyed by phone number, then topic
+	subscriptions map[string]map[Topic]Subscription
+
+	seen map[Topic][]string
+
+	optOuts map[string]bool
+
+	preferences map[string]Preferences
+
+	sessions map[string]Session
+}
+
+// NewMemory returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		subscriptions: map[string]map[Topic]Subscription{},
+		seen:          map[Topic][]string{},
+		optOuts:       map[string]bool{},
+		preferences:   map[string]Preferences{},
+		sessions:      map[string]Session{},
+	}
+}
+
+func (m *Memory) Subscribe(ctx context.Context, sub Subscription) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	topics, ok := m.subscriptions[sub.Phone]
+	if !ok {
+		topics = map[Topic]Subscription{}
+		m.subscriptions[sub.Phone] = topics
+	}
+
+	if _, ok := topics[sub.Topic]; ok {
+		return false, nil
+	}
+	topics[sub.Topic] = sub
+
+	return true, nil
+}
+
+func (m *Memory) Unsubscribe(ctx context.Context, phone string, topic Topic) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	topics := m.subscriptions[phone]
+	if _, ok := topics[topic]; !ok {
+		return false, nil
+	}
+
+	delete(topics, topic)
+	if len(topics) == 0 {
+		delete(m.subscriptions, phone)
+	}
+
+	return true, nil
+}
+
+func (m *Memory) Subscriptions(ctx context.Context, phone string) ([]Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subs := []Subscription{}
+	for _, sub := range m.subscriptions[phone] {
+		subs = append(subs, sub)
+	}
+	sortSubscriptions(subs)
+
+	return subs, nil
+}
+
+func (m *Memory) Topics(ctx context.Context) ([]Topic, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	unique := map[Topic]bool{}
+	for _, topics := range m.subscriptions {
+		for topic := range topics {
+			unique[topic] = true
+		}
+	}
+
+	topics := make([]Topic, 0, len(unique))
+	for topic := range unique {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].String() < topics[j].String() })
+
+	return topics, nil
+}
+
+func (m *Memory) Subscribers(ctx context.Context, topic Topic) ([]Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subs := []Subscription{}
+	for _, topics := range m.subscriptions {
+		if sub, ok := topics[topic]; ok {
+			subs = append(subs, sub)
+		}
+	}
+	sortSubscriptions(subs)
+
+	return subs, nil
+}
+
+func (m *Memory) SeenAlerts(ctx context.Context, topic Topic) ([]string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids, ok := m.seen[topic]
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]string{}, ids...), true, nil
+}
+
+func (m *Memory) SetSeenAlerts(ctx context.Context, topic Topic, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seen[topic] = append([]string{}, ids...)
+	return nil
+}
+
+func (m *Memory) OptOut(ctx context.Context, phone string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.optOuts[phone] {
+		return false, nil
+	}
+	m.optOuts[phone] = true
+	return true, nil
+}
+
+func (m *Memory) OptIn(ctx context.Context, phone string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.optOuts[phone] {
+		return false, nil
+	}
+	delete(m.optOuts, phone)
+	return true, nil
+}
+
+func (m *Memory) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.optOuts[phone], nil
+}
+
+func (m *Memory) Preferences(ctx context.Context, phone string) (Preferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.preferences[phone], nil
+}
+
+func (m *Memory) SetPreferences(ctx context.Context, phone string, prefs Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if prefs == (Preferences{}) {
+		delete(m.preferences, phone)
+		return nil
+	}
+	m.preferences[phone] = prefs
+	return nil
+}
+
+func (m *Memory) Session(ctx context.Context, phone string) (Session, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[phone]
+	if !ok {
+		return Session{}, false, nil
+	}
+	return copySession(session), true, nil
+}
+
+func (m *Memory) SetSession(ctx context.Context, phone string, session Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[phone] = copySession(session)
+	return nil
+}
+
+func (m *Memory) DeleteSession(ctx context.Context, phone string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, phone)
+	return nil
+}
+
+func (m *Memory) ExpireSessions(ctx context.Context, cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := 0
+	for phone, session := range m.sessions {
+		if session.UpdatedAt.Before(cutoff) {
+			delete(m.sessions, phone)
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// forgetSeen drops the seen alerts of topic, for the file store to roll back
+// a failed save.
+func (m *Memory) forgetSeen(topic Topic) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.seen, topic)
+}
+
+// all returns every subscription, for the file store to persist.
+func (m *Memory) all() []Subscription {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subs := []Subscription{}
+	for _, topics := range m.subscriptions {
+		for _, sub := range topics {
+			subs = append(subs, sub)
+		}
+	}
+	sortSubscriptions(subs)
+
+	return subs
+}
+
+// allSeen returns the seen alerts of every topic, for the file store to
+// persist.
+func (m *Memory) allSeen() []seenAlerts {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make([]seenAlerts, 0, len(m.seen))
+	for topic, ids := range m.seen {
+		seen = append(seen, seenAlerts{Topic: topic, IDs: append([]string{}, ids...)})
+	}
+	sort.Slice(seen, func(i, j int) bool { return seen[i].Topic.String() < seen[j].Topic.String() })
+
+	return seen
+}
+
+// allOptOuts returns every opted out phone number, for the file store to
+// persist.
+func (m *Memory) allOptOuts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	phones := make([]string, 0, len(m.optOuts))
+	for phone := range m.optOuts {
+		phones = append(phones, phone)
+	}
+	sort.Strings(phones)
+
+	return phones
+}
+
+// allPreferences returns the preferences of every phone number, for the
+// file store to persist.
+func (m *Memory) allPreferences() []phonePreferences {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefs := make([]phonePreferences, 0, len(m.preferences))
+	for phone, p := range m.preferences {
+		prefs = append(prefs, phonePreferences{Phone: phone, Preferences: p})
+	}
+	sort.Slice(prefs, func(i, j int) bool { return prefs[i].Phone < prefs[j].Phone })
+
+	return prefs
+}
+
+// copySession returns session with its own copy of the alerts, so callers
+// cannot change what is stored.
+func copySession(session Session) Session {
+	session.Alerts = append([]SessionAlert{}, session.Alerts...)
+	return session
+}
+
+// sortSubscriptions orders subscriptions oldest first, breaking ties by
+// phone number and topic so the order is stable.
+func sortSubscriptions(subs []Subscription) {
+	sort.Slice(subs, func(i, j int) bool {
+		a, b := subs[i], subs[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.Phone != b.Phone {
+			return a.Phone < b.Phone
+		}
+		return a.Topic.String() < b.Topic.String()
+	})
+}
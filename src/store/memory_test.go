@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	utah     = Topic{Kind: TopicState, Code: "UT"}
+	yosemite = Topic{Kind: TopicPark, Code: "yose"}
+)
+
+func TestMemorySubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	created, err := m.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	assert.Nil(err)
+	assert.True(created)
+
+	created, err = m.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	assert.Nil(err)
+	assert.False(created)
+
+	subs, _ := m.Subscriptions(ctx, "+1555")
+	assert.Len(subs, 1)
+}
+
+func TestMemorySubscriptionsOldestFirst(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	now := time.Now()
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1555", Topic: yosemite, Name: "Yosemite", CreatedAt: now})
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: now.Add(-time.Hour)})
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1666", Topic: utah, Name: "Utah", CreatedAt: now})
+
+	subs, err := m.Subscriptions(ctx, "+1555")
+
+	assert.Nil(err)
+	assert.Len(subs, 2)
+	assert.Equal(utah, subs[0].Topic)
+	assert.Equal(yosemite, subs[1].Topic)
+}
+
+func TestMemoryUnsubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	removed, err := m.Unsubscribe(ctx, "+1555", yosemite)
+	assert.Nil(err)
+	assert.False(removed)
+
+	removed, err = m.Unsubscribe(ctx, "+1555", utah)
+	assert.Nil(err)
+	assert.True(removed)
+
+	subs, _ := m.Subscriptions(ctx, "+1555")
+	assert.Empty(subs)
+}
+
+func TestMemoryTopicsAndSubscribers(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	now := time.Now()
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: now})
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1666", Topic: utah, Name: "Utah", CreatedAt: now.Add(-time.Hour)})
+	_, _ = m.Subscribe(ctx, Subscription{Phone: "+1666", Topic: yosemite, Name: "Yosemite", CreatedAt: now})
+
+	topics, err := m.Topics(ctx)
+
+	assert.Nil(err)
+	assert.Equal([]Topic{yosemite, utah}, topics)
+
+	subs, err := m.Subscribers(ctx, utah)
+
+	assert.Nil(err)
+	assert.Len(subs, 2)
+	assert.Equal("+1666", subs[0].Phone)
+	assert.Equal("+1555", subs[1].Phone)
+
+	_, _ = m.Unsubscribe(ctx, "+1666", yosemite)
+
+	topics, _ = m.Topics(ctx)
+	assert.Equal([]Topic{utah}, topics)
+}
+
+func TestMemorySeenAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	_, ok, err := m.SeenAlerts(ctx, utah)
+	assert.Nil(err)
+	assert.False(ok)
+
+	_ = m.SetSeenAlerts(ctx, utah, []string{"1", "2"})
+
+	seen, ok, err := m.SeenAlerts(ctx, utah)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal([]string{"1", "2"}, seen)
+
+	// an empty set still records that the topic was polled
+	_ = m.SetSeenAlerts(ctx, yosemite, nil)
+
+	seen, ok, _ = m.SeenAlerts(ctx, yosemite)
+	assert.True(ok)
+	assert.Empty(seen)
+}
+
+func TestMemoryOptOut(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	optedOut, err := m.IsOptedOut(ctx, "+1555")
+	assert.Nil(err)
+	assert.False(optedOut)
+
+	added, err := m.OptOut(ctx, "+1555")
+	assert.Nil(err)
+	assert.True(added)
+
+	added, _ = m.OptOut(ctx, "+1555")
+	assert.False(added)
+
+	optedOut, _ = m.IsOptedOut(ctx, "+1555")
+	assert.True(optedOut)
+
+	removed, err := m.OptIn(ctx, "+1555")
+	assert.Nil(err)
+	assert.True(removed)
+
+	removed, _ = m.OptIn(ctx, "+1555")
+	assert.False(removed)
+
+	optedOut, _ = m.IsOptedOut(ctx, "+1555")
+	assert.False(optedOut)
+}
+
+func TestMemoryPreferences(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+
+	prefs, err := m.Preferences(ctx, "+1555")
+	assert.Nil(err)
+	assert.Equal(Preferences{}, prefs)
+
+	assert.Nil(m.SetPreferences(ctx, "+1555", Preferences{HomeState: "UT"}))
+
+	prefs, _ = m.Preferences(ctx, "+1555")
+	assert.Equal(Preferences{HomeState: "UT"}, prefs)
+
+	assert.Nil(m.SetPreferences(ctx, "+1555", Preferences{}))
+
+	assert.Empty(m.allPreferences())
+}
+
+func testSession(updated time.Time) Session {
+	return Session{
+		Topic: utah,
+		Name:  "Utah",
+		Alerts: []SessionAlert{
+			{ParkName: "Arches", StateCode: "UT", Header: "Devils Garden closed", Message: "The trail is closed.", URL: "https://www.nps.gov/arch", Published: updated.Add(-time.Hour)},
+			{ParkName: "Zion", StateCode: "UT", Header: "Shuttle delays", PublishedDate: "2022-08-01 10:00:00.0"},
+		},
+		Next:      1,
+		UpdatedAt: updated,
+	}
+}
+
+func TestMemorySessions(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	_, ok, err := m.Session(ctx, "+1555")
+	assert.Nil(err)
+	assert.False(ok)
+
+	assert.Nil(m.SetSession(ctx, "+1555", testSession(now)))
+	assert.Nil(m.SetSession(ctx, "+1666", testSession(now.Add(-time.Hour))))
+
+	session, ok, err := m.Session(ctx, "+1555")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(testSession(now), session)
+
+	// changing the returned session does not change the stored one
+	session.Alerts[0].Header = "changed"
+	session, _, _ = m.Session(ctx, "+1555")
+	assert.Equal("Devils Garden closed", session.Alerts[0].Header)
+
+	expired, err := m.ExpireSessions(ctx, now.Add(-time.Minute))
+	assert.Nil(err)
+	assert.Equal(1, expired)
+
+	_, ok, _ = m.Session(ctx, "+1666")
+	assert.False(ok)
+
+	assert.Nil(m.DeleteSession(ctx, "+1555"))
+	_, ok, _ = m.Session(ctx, "+1555")
+	assert.False(ok)
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations holds the SQLite schema as numbered files such as
+// 0001_create_subscriptions.sql. Each file runs once, in order, inside its own
+// transaction. Never edit a file that has shipped; add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrate brings the schema of db up to date and returns the version it is
+// now at.
+func migrate(ctx context.Context, db *sql.DB) (int, error) {
+
+	pending, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	return applyMigrations(ctx, db, pending)
+}
+
+// applyMigrations runs the migrations in pending that db has not had yet.
+func applyMigrations(ctx context.Context, db *sql.DB, pending []migration) (int, error) {
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT    NOT NULL,
+		applied_at TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return 0, fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
+
+	current := 0
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return 0, fmt.Errorf("cannot read schema version: %w", err)
+	}
+
+	for _, m := range pending {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return current, fmt.Errorf("migration %s failed: %w", m.name, err)
+		}
+		current = m.version
+	}
+
+	return current, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loadMigrations returns the embedded migrations sorted by version.
+func loadMigrations() ([]migration, error) {
+
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]migration, 0, len(entries))
+	seen := map[int]string{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		prefix := strings.SplitN(name, "_", 2)[0]
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s does not start with a version number", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		content, err := migrations.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		loaded = append(loaded, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].version < loaded[j].version })
+
+	return loaded, nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	loaded, err := loadMigrations()
+
+	assert.Nil(err)
+	assert.NotEmpty(loaded)
+	for i, m := range loaded {
+		assert.Equal(i+1, m.version, "migrations must be numbered without gaps")
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(err)
+	defer db.Close()
+
+	loaded, _ := loadMigrations()
+	latest := loaded[len(loaded)-1].version
+
+	version, err := migrate(ctx, db)
+	assert.Nil(err)
+	assert.Equal(latest, version)
+
+	version, err = migrate(ctx, db)
+	assert.Nil(err)
+	assert.Equal(latest, version)
+
+	applied := 0
+	_ = db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	assert.Equal(len(loaded), applied)
+}
+
+func TestMigrateFromOlderVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(err)
+	defer db.Close()
+
+	// a database created when only the first migration existed
+	loaded, _ := loadMigrations()
+	version, err := applyMigrations(ctx, db, loaded[:1])
+	assert.Nil(err)
+	assert.Equal(1, version)
+
+	_, err = db.Exec("INSERT INTO subscriptions (phone, topic_kind, topic_code, name, created_at) VALUES ('+1555', 'state', 'UT', 'Utah', 0)")
+	assert.Nil(err)
+
+	version, err = migrate(ctx, db)
+
+	assert.Nil(err)
+	assert.Equal(loaded[len(loaded)-1].version, version)
+
+	_, err = db.Exec("SELECT alert_id FROM seen_alerts")
+	assert.Nil(err)
+
+	// existing rows survive
+	count := 0
+	_ = db.QueryRow("SELECT COUNT(*) FROM subscriptions").Scan(&count)
+	assert.Equal(1, count)
+}
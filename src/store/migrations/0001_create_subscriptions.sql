@@ -0,0 +1,10 @@
+CREATE TABLE subscriptions (
+    phone      TEXT    NOT NULL,
+    topic_kind TEXT    NOT NULL,
+    topic_code TEXT    NOT NULL,
+    name       TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (phone, topic_kind, topic_code)
+);
+
+CREATE INDEX subscriptions_topic ON subscriptions (topic_kind, topic_code);
@@ -0,0 +1,14 @@
+-- polled_topics records topics polled at least once, even when they had no
+-- alerts, so the poller knows not to seed them again.
+CREATE TABLE polled_topics (
+    topic_kind TEXT NOT NULL,
+    topic_code TEXT NOT NULL,
+    PRIMARY KEY (topic_kind, topic_code)
+);
+
+CREATE TABLE seen_alerts (
+    topic_kind TEXT NOT NULL,
+    topic_code TEXT NOT NULL,
+    alert_id   TEXT NOT NULL,
+    PRIMARY KEY (topic_kind, topic_code, alert_id)
+);
@@ -0,0 +1,4 @@
+CREATE TABLE opt_outs (
+    phone      TEXT    PRIMARY KEY,
+    created_at INTEGER NOT NULL
+);
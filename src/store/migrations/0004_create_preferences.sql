@@ -0,0 +1,4 @@
+CREATE TABLE preferences (
+    phone      TEXT PRIMARY KEY,
+    home_state TEXT NOT NULL DEFAULT ''
+);
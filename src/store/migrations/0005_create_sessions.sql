@@ -0,0 +1,9 @@
+-- session is the Session as JSON. It is only ever read and written whole,
+-- and updated_at is kept alongside it so idle sessions can be expired.
+CREATE TABLE sessions (
+    phone      TEXT PRIMARY KEY,
+    session    TEXT NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE INDEX sessions_updated_at ON sessions (updated_at);
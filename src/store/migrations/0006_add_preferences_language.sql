@@ -0,0 +1 @@
+ALTER TABLE preferences ADD COLUMN language TEXT NOT NULL DEFAULT '';
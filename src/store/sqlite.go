@@ -0,0 +1,325 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	// pure Go SQLite driver, registered as "sqlite", so the binary still
+	// builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+// SQLite is a Store backed by an embedded SQLite database.
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite opens the SQLite database at path, creating it if needed, and
+// migrates its schema to the latest version.
+func NewSQLite(path string) (*SQLite, error) {
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows one writer at a time. A single connection avoids "database
+	// is locked" errors and keeps the pragmas below in effect.
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+		"PRAGMA foreign_keys = ON",
+	} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("cannot set %q: %w", pragma, err)
+		}
+	}
+
+	if _, err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Subscribe(ctx context.Context, sub Subscription) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO subscriptions (phone, topic_kind, topic_code, name, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		sub.Phone, sub.Topic.Kind, sub.Topic.Code, sub.Name, toUnixNano(sub.CreatedAt))
+	if err != nil {
+		return false, err
+	}
+	return changed(res)
+}
+
+func (s *SQLite) Unsubscribe(ctx context.Context, phone string, topic Topic) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM subscriptions
+		WHERE phone = ? AND topic_kind = ? AND topic_code = ?`,
+		phone, topic.Kind, topic.Code)
+	if err != nil {
+		return false, err
+	}
+	return changed(res)
+}
+
+func (s *SQLite) Subscriptions(ctx context.Context, phone string) ([]Subscription, error) {
+	return s.querySubscriptions(ctx, `
+		SELECT phone, topic_kind, topic_code, name, created_at
+		FROM subscriptions
+		WHERE phone = ?
+		ORDER BY created_at, phone, topic_kind || ':' || topic_code`,
+		phone)
+}
+
+func (s *SQLite) Topics(ctx context.Context) ([]Topic, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT topic_kind, topic_code
+		FROM subscriptions
+		ORDER BY topic_kind || ':' || topic_code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []Topic{}
+	for rows.Next() {
+		topic := Topic{}
+		if err := rows.Scan(&topic.Kind, &topic.Code); err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+	return topics, rows.Err()
+}
+
+func (s *SQLite) Subscribers(ctx context.Context, topic Topic) ([]Subscription, error) {
+	return s.querySubscriptions(ctx, `
+		SELECT phone, topic_kind, topic_code, name, created_at
+		FROM subscriptions
+		WHERE topic_kind = ? AND topic_code = ?
+		ORDER BY created_at, phone`,
+		topic.Kind, topic.Code)
+}
+
+func (s *SQLite) SeenAlerts(ctx context.Context, topic Topic) ([]string, bool, error) {
+
+	polled := 0
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM polled_topics
+		WHERE topic_kind = ? AND topic_code = ?`,
+		topic.Kind, topic.Code).Scan(&polled); err != nil {
+		return nil, false, err
+	}
+	if polled == 0 {
+		return nil, false, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT alert_id FROM seen_alerts
+		WHERE topic_kind = ? AND topic_code = ?
+		ORDER BY rowid`,
+		topic.Kind, topic.Code)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, false, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, true, rows.Err()
+}
+
+func (s *SQLite) SetSeenAlerts(ctx context.Context, topic Topic, ids []string) error {
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO polled_topics (topic_kind, topic_code) VALUES (?, ?)
+		ON CONFLICT DO NOTHING`,
+		topic.Kind, topic.Code); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM seen_alerts WHERE topic_kind = ? AND topic_code = ?`,
+		topic.Kind, topic.Code); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO seen_alerts (topic_kind, topic_code, alert_id) VALUES (?, ?, ?)
+			ON CONFLICT DO NOTHING`,
+			topic.Kind, topic.Code, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite) OptOut(ctx context.Context, phone string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO opt_outs (phone, created_at) VALUES (?, ?)
+		ON CONFLICT DO NOTHING`,
+		phone, toUnixNano(time.Now()))
+	if err != nil {
+		return false, err
+	}
+	return changed(res)
+}
+
+func (s *SQLite) OptIn(ctx context.Context, phone string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM opt_outs WHERE phone = ?", phone)
+	if err != nil {
+		return false, err
+	}
+	return changed(res)
+}
+
+func (s *SQLite) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	count := 0
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM opt_outs WHERE phone = ?", phone).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *SQLite) Preferences(ctx context.Context, phone string) (Preferences, error) {
+	prefs := Preferences{}
+	err := s.db.QueryRowContext(ctx, "SELECT home_state, language FROM preferences WHERE phone = ?", phone).
+		Scan(&prefs.HomeState, &prefs.Language)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Preferences{}, nil
+	}
+	return prefs, err
+}
+
+func (s *SQLite) SetPreferences(ctx context.Context, phone string, prefs Preferences) error {
+	if prefs == (Preferences{}) {
+		_, err := s.db.ExecContext(ctx, "DELETE FROM preferences WHERE phone = ?", phone)
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO preferences (phone, home_state, language) VALUES (?, ?, ?)
+		ON CONFLICT (phone) DO UPDATE SET home_state = excluded.home_state, language = excluded.language`,
+		phone, prefs.HomeState, prefs.Language)
+	return err
+}
+
+func (s *SQLite) Session(ctx context.Context, phone string) (Session, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, "SELECT session FROM sessions WHERE phone = ?", phone).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, err
+	}
+
+	session := Session{}
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return Session{}, false, fmt.Errorf("cannot read session of %s: %w", phone, err)
+	}
+	return session, true, nil
+}
+
+func (s *SQLite) SetSession(ctx context.Context, phone string, session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO sessions (phone, session, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (phone) DO UPDATE SET session = excluded.session, updated_at = excluded.updated_at`,
+		phone, string(data), toUnixNano(session.UpdatedAt))
+	return err
+}
+
+func (s *SQLite) DeleteSession(ctx context.Context, phone string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE phone = ?", phone)
+	return err
+}
+
+func (s *SQLite) ExpireSessions(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE updated_at < ?", toUnixNano(cutoff))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		sub := Subscription{}
+		var createdAt int64
+		if err := rows.Scan(&sub.Phone, &sub.Topic.Kind, &sub.Topic.Code, &sub.Name, &createdAt); err != nil {
+			return nil, err
+		}
+		sub.CreatedAt = fromUnixNano(createdAt)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// toUnixNano stores times as nanoseconds since the epoch so they sort
+// correctly in SQL. The zero time is stored as 0.
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// changed reports whether a statement affected any rows.
+func changed(res sql.Result) (bool, error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSQLite(t *testing.T) *SQLite {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteSubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	created := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	ok, err := s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: created})
+	assert.Nil(err)
+	assert.True(ok)
+
+	ok, err = s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: created})
+	assert.Nil(err)
+	assert.False(ok)
+
+	subs, err := s.Subscriptions(ctx, "+1555")
+
+	assert.Nil(err)
+	assert.Equal([]Subscription{
+		{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: created},
+	}, subs)
+}
+
+func TestSQLiteSubscriptionsOldestFirst(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	now := time.Now()
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: yosemite, Name: "Yosemite", CreatedAt: now})
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: now.Add(-time.Hour)})
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1666", Topic: utah, Name: "Utah", CreatedAt: now})
+
+	subs, err := s.Subscriptions(ctx, "+1555")
+
+	assert.Nil(err)
+	assert.Len(subs, 2)
+	assert.Equal(utah, subs[0].Topic)
+	assert.Equal(yosemite, subs[1].Topic)
+}
+
+func TestSQLiteUnsubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+
+	removed, err := s.Unsubscribe(ctx, "+1555", yosemite)
+	assert.Nil(err)
+	assert.False(removed)
+
+	removed, err = s.Unsubscribe(ctx, "+1555", utah)
+	assert.Nil(err)
+	assert.True(removed)
+
+	subs, _ := s.Subscriptions(ctx, "+1555")
+	assert.Empty(subs)
+}
+
+func TestSQLiteTopicsAndSubscribers(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	now := time.Now()
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah", CreatedAt: now})
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1666", Topic: utah, Name: "Utah", CreatedAt: now.Add(-time.Hour)})
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1666", Topic: yosemite, Name: "Yosemite", CreatedAt: now})
+
+	topics, err := s.Topics(ctx)
+
+	assert.Nil(err)
+	assert.Equal([]Topic{yosemite, utah}, topics)
+
+	subs, err := s.Subscribers(ctx, utah)
+
+	assert.Nil(err)
+	assert.Len(subs, 2)
+	assert.Equal("+1666", subs[0].Phone)
+	assert.Equal("+1555", subs[1].Phone)
+}
+
+func TestSQLiteSeenAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	_, ok, err := s.SeenAlerts(ctx, utah)
+	assert.Nil(err)
+	assert.False(ok)
+
+	_ = s.SetSeenAlerts(ctx, utah, []string{"3", "1", "2"})
+
+	seen, ok, err := s.SeenAlerts(ctx, utah)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal([]string{"3", "1", "2"}, seen)
+
+	_ = s.SetSeenAlerts(ctx, utah, []string{"4"})
+
+	seen, _, _ = s.SeenAlerts(ctx, utah)
+	assert.Equal([]string{"4"}, seen)
+
+	// an empty set still records that the topic was polled
+	_ = s.SetSeenAlerts(ctx, yosemite, nil)
+
+	seen, ok, _ = s.SeenAlerts(ctx, yosemite)
+	assert.True(ok)
+	assert.Empty(seen)
+}
+
+func TestSQLitePersists(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	s, err := NewSQLite(path)
+	assert.Nil(err)
+
+	_, _ = s.Subscribe(ctx, Subscription{Phone: "+1555", Topic: utah, Name: "Utah"})
+	_ = s.SetSeenAlerts(ctx, utah, []string{"1"})
+	assert.Nil(s.Close())
+
+	reopened, err := NewSQLite(path)
+	assert.Nil(err)
+	defer reopened.Close()
+
+	subs, _ := reopened.Subscriptions(ctx, "+1555")
+	assert.Len(subs, 1)
+
+	seen, ok, _ := reopened.SeenAlerts(ctx, utah)
+	assert.True(ok)
+	assert.Equal([]string{"1"}, seen)
+}
+
+func TestSQLiteOptOut(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	optedOut, err := s.IsOptedOut(ctx, "+1555")
+	assert.Nil(err)
+	assert.False(optedOut)
+
+	added, err := s.OptOut(ctx, "+1555")
+	assert.Nil(err)
+	assert.True(added)
+
+	added, _ = s.OptOut(ctx, "+1555")
+	assert.False(added)
+
+	optedOut, _ = s.IsOptedOut(ctx, "+1555")
+	assert.True(optedOut)
+
+	removed, err := s.OptIn(ctx, "+1555")
+	assert.Nil(err)
+	assert.True(removed)
+
+	optedOut, _ = s.IsOptedOut(ctx, "+1555")
+	assert.False(optedOut)
+}
+
+func TestSQLitePreferences(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	prefs, err := s.Preferences(ctx, "+1555")
+	assert.Nil(err)
+	assert.Equal(Preferences{}, prefs)
+
+	assert.Nil(s.SetPreferences(ctx, "+1555", Preferences{HomeState: "UT"}))
+	assert.Nil(s.SetPreferences(ctx, "+1555", Preferences{HomeState: "AZ", Language: "es"}))
+
+	prefs, _ = s.Preferences(ctx, "+1555")
+	assert.Equal(Preferences{HomeState: "AZ", Language: "es"}, prefs)
+
+	assert.Nil(s.SetPreferences(ctx, "+1555", Preferences{}))
+
+	count := 0
+	_ = s.db.QueryRow("SELECT COUNT(*) FROM preferences").Scan(&count)
+	assert.Equal(0, count)
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newTestSQLite(t)
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	_, ok, err := s.Session(ctx, "+1555")
+	assert.Nil(err)
+	assert.False(ok)
+
+	assert.Nil(s.SetSession(ctx, "+1555", testSession(now.Add(-time.Hour))))
+	assert.Nil(s.SetSession(ctx, "+1555", testSession(now)))
+	assert.Nil(s.SetSession(ctx, "+1666", testSession(now.Add(-time.Hour))))
+
+	session, ok, err := s.Session(ctx, "+1555")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(testSession(now), session)
+
+	expired, err := s.ExpireSessions(ctx, now.Add(-time.Minute))
+	assert.Nil(err)
+	assert.Equal(1, expired)
+
+	_, ok, _ = s.Session(ctx, "+1666")
+	assert.False(ok)
+
+	assert.Nil(s.DeleteSession(ctx, "+1555"))
+
+	count := 0
+	_ = s.db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count)
+	assert.Equal(0, count)
+}
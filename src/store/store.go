@@ -0,0 +1,208 @@
+// Package store persists the state texters build up with the service, such as
+// their alert subscriptions and opt-outs, and which alerts they have already
+// been sent.
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// TopicState is a subscription to every alert in a state, keyed by the
+	// upper-case state code.
+	TopicState = "state"
+
+	// TopicPark is a subscription to the alerts of one park, keyed by the
+	// lower-case park code.
+	TopicPark = "park"
+)
+
+// Topic is something a texter can subscribe to, such as the state "UT" or the
+// park "yose".
+type Topic struct {
+	Kind string `json:"kind"`
+	Code string `json:"code"`
+}
+
+func (t Topic) String() string {
+	return fmt.Sprintf("%s:%s", t.Kind, t.Code)
+}
+
+// Subscription is a phone number's request to be texted new alerts for a
+// topic.
+type Subscription struct {
+	Phone string `json:"phone"`
+	Topic Topic  `json:"topic"`
+
+	// Name is how the topic is shown to the texter, e.g. "Utah" or "Yosemite".
+	Name string `json:"name"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SubscriptionStore keeps alert subscriptions keyed by phone number.
+type SubscriptionStore interface {
+	// Subscribe adds sub. It returns false when the phone number is already
+	// subscribed to the topic, leaving the existing subscription unchanged.
+	Subscribe(ctx context.Context, sub Subscription) (bool, error)
+
+	// Unsubscribe removes the phone number's subscription to topic. It returns
+	// false when there was no such subscription.
+	Unsubscribe(ctx context.Context, phone string, topic Topic) (bool, error)
+
+	// Subscriptions returns the phone number's subscriptions, oldest first.
+	Subscriptions(ctx context.Context, phone string) ([]Subscription, error)
+
+	// Topics returns every topic with at least one subscriber.
+	Topics(ctx context.Context) ([]Topic, error)
+
+	// Subscribers returns the subscriptions to topic, oldest first.
+	Subscribers(ctx context.Context, topic Topic) ([]Subscription, error)
+}
+
+// SeenStore remembers which alerts subscribers of each topic have already
+// been sent, so a restart does not send them again.
+type SeenStore interface {
+	// SeenAlerts returns the IDs of the alerts already handled for topic. It
+	// returns false when the topic has never been polled.
+	SeenAlerts(ctx context.Context, topic Topic) ([]string, bool, error)
+
+	// SetSeenAlerts replaces the alerts handled for topic with ids.
+	SetSeenAlerts(ctx context.Context, topic Topic, ids []string) error
+}
+
+// OptOutStore keeps the phone numbers that have texted STOP or another
+// opt-out keyword. Nothing may be texted to them until they opt back in.
+type OptOutStore interface {
+	// OptOut records that phone opted out. It returns false when it already
+	// had.
+	OptOut(ctx context.Context, phone string) (bool, error)
+
+	// OptIn clears an opt-out. It returns false when phone had not opted out.
+	OptIn(ctx context.Context, phone string) (bool, error)
+
+	// IsOptedOut reports whether phone has opted out.
+	IsOptedOut(ctx context.Context, phone string) (bool, error)
+}
+
+// Preferences are the settings a texter has chosen for their phone number.
+type Preferences struct {
+	// HomeState is the state code "alerts" answers for when no state or park
+	// is given.
+	HomeState string `json:"homeState,omitempty"`
+
+	// Language is the code of the language replies are sent in, e.g. "es",
+	// or empty for the default.
+	Language string `json:"language,omitempty"`
+}
+
+// PreferenceStore keeps each phone number's Preferences.
+type PreferenceStore interface {
+	// Preferences returns the preferences of phone, which are the zero value
+	// when none have been saved.
+	Preferences(ctx context.Context, phone string) (Preferences, error)
+
+	// SetPreferences replaces the preferences of phone.
+	SetPreferences(ctx context.Context, phone string, prefs Preferences) error
+}
+
+// Session is a list of alerts a texter asked for and how far they have paged
+// through it, so "more" and "read 3" can follow on from "alerts CA".
+type Session struct {
+	// Topic is the state or park the alerts are for.
+	Topic Topic `json:"topic"`
+
+	// Name is how the topic is shown to the texter, e.g. "California".
+	Name string `json:"name"`
+
+	// Category is the alert category the list was narrowed to, if any.
+	Category string `json:"category,omitempty"`
+
+	// Alerts are newest first.
+	Alerts []SessionAlert `json:"alerts"`
+
+	// Next is the index in Alerts of the first alert of the next page.
+	Next int `json:"next"`
+
+	// UpdatedAt is when the texter last used the session.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SessionAlert is what a Session keeps of an alert to text it in full later.
+type SessionAlert struct {
+	ParkName  string    `json:"parkName"`
+	StateCode string    `json:"stateCode"`
+	Header    string    `json:"header"`
+	Message   string    `json:"message"`
+	URL       string    `json:"url"`
+	Published time.Time `json:"published"`
+	// PublishedDate is the date as NPS sent it, for when it could not be
+	// parsed into Published.
+	PublishedDate string `json:"publishedDate,omitempty"`
+}
+
+// SessionStore keeps each phone number's current Session.
+type SessionStore interface {
+	// Session returns the session of phone. It returns false when there is
+	// none.
+	Session(ctx context.Context, phone string) (Session, bool, error)
+
+	// SetSession replaces the session of phone.
+	SetSession(ctx context.Context, phone string, session Session) error
+
+	// DeleteSession removes the session of phone, if there is one.
+	DeleteSession(ctx context.Context, phone string) error
+
+	// ExpireSessions removes every session last used before cutoff and
+	// returns how many there were.
+	ExpireSessions(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// Store is everything the service persists.
+type Store interface {
+	SubscriptionStore
+	SeenStore
+	OptOutStore
+	PreferenceStore
+	SessionStore
+
+	// Close releases the store's resources. It must not be used afterwards.
+	Close() error
+}
+
+// Drivers accepted by Open.
+const (
+	DriverMemory = "memory"
+	DriverFile   = "file"
+	DriverSQLite = "sqlite"
+)
+
+// Open returns the store for driver, kept at path. An empty driver picks the
+// JSON file store when path is set and the memory store otherwise, which is
+// how STORE_PATH behaved before drivers existed.
+func Open(driver, path string) (Store, error) {
+
+	if driver == "" {
+		driver = DriverMemory
+		if path != "" {
+			driver = DriverFile
+		}
+	}
+
+	switch driver {
+	case DriverMemory:
+		return NewMemory(), nil
+	case DriverFile, DriverSQLite:
+		if path == "" {
+			return nil, fmt.Errorf("the %s store needs a path", driver)
+		}
+		if driver == DriverFile {
+			return NewFile(path)
+		}
+		return NewSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", driver)
+	}
+}
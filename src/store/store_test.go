@@ -0,0 +1,52 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := Open("", "")
+
+	assert.Nil(err)
+	assert.IsType(&Memory{}, s)
+}
+
+func TestOpenFileByPath(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := Open("", filepath.Join(t.TempDir(), "store.json"))
+
+	assert.Nil(err)
+	assert.IsType(&File{}, s)
+}
+
+func TestOpenSQLite(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "store.db"))
+
+	assert.Nil(err)
+	assert.IsType(&SQLite{}, s)
+	assert.Nil(s.Close())
+}
+
+func TestOpenMissingPath(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Open(DriverSQLite, "")
+
+	assert.EqualError(err, "the sqlite store needs a path")
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Open("postgres", "")
+
+	assert.EqualError(err, `unknown store driver "postgres"`)
+}
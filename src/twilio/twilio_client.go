@@ -1,13 +1,26 @@
 package twilio
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/WilliamDeBruin/nps_alerts/src/logging"
 	"github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+	"go.uber.org/zap"
 )
 
+// ErrOptedOut is returned by SendMessage when the recipient has opted out of
+// messages by texting STOP or a similar keyword.
+var ErrOptedOut = errors.New("recipient has opted out of messages")
+
+// OptOutChecker reports whether a phone number has opted out of messages.
+type OptOutChecker interface {
+	IsOptedOut(ctx context.Context, phone string) (bool, error)
+}
+
 type TwilioRestClientApi interface {
 	CreateMessage(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error)
 }
@@ -15,26 +28,77 @@ type TwilioRestClientApi interface {
 type fetcher struct {
 	API        TwilioRestClientApi
 	fromNumber string
+	optOuts    OptOutChecker
+	timeout    time.Duration
+}
+
+// Option configures optional behaviour of a Client created by NewClient.
+type Option func(*fetcher)
+
+// WithTimeout sets the timeout for each HTTP request to the Twilio API.
+// Values of zero or less keep the default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(f *fetcher) {
+		if timeout > 0 {
+			f.timeout = timeout
+		}
+	}
+}
+
+// WithOptOutChecker makes SendMessage refuse to text numbers that checker
+// reports as opted out, returning ErrOptedOut.
+func WithOptOutChecker(checker OptOutChecker) Option {
+	return func(f *fetcher) {
+		f.optOuts = checker
+	}
 }
 
 type Client interface {
-	SendMessage(to, message string) error
+	SendMessage(ctx context.Context, to, message string) error
 }
 
-func NewClient(fromNumber string) (Client, error) {
+func NewClient(fromNumber string, opts ...Option) (Client, error) {
 
 	if fromNumber == "" {
 		return nil, fmt.Errorf("fromNumber cannot be empty")
 	}
 
 	client := twilio.NewRestClient()
-	return &fetcher{
+	f := &fetcher{
 		API:        client.Api,
 		fromNumber: fromNumber,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if f.timeout > 0 {
+		client.SetTimeout(f.timeout)
+	}
+
+	return f, nil
 }
 
-func (c *fetcher) SendMessage(to, message string) error {
+// SendMessage sends message to the given number. The Twilio SDK cannot cancel
+// an in-flight request, so ctx is only checked before sending; the request
+// itself is bounded by the client timeout. Numbers that have opted out are
+// never texted.
+func (c *fetcher) SendMessage(ctx context.Context, to, message string) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.optOuts != nil {
+		optedOut, err := c.optOuts.IsOptedOut(ctx, to)
+		if err != nil {
+			return fmt.Errorf("cannot check opt-outs: %w", err)
+		}
+		if optedOut {
+			return ErrOptedOut
+		}
+	}
 
 	params := &openapi.CreateMessageParams{}
 	params.SetTo(to)
@@ -50,13 +114,14 @@ func (c *fetcher) SendMessage(to, message string) error {
 		return fmt.Errorf("error in Twilio CreateMessage.\n\nError code: %d\n\nError Message: %s", *resp.ErrorCode, *resp.ErrorMessage)
 	}
 
-	response, err := json.Marshal(*resp)
-
-	fmt.Println("Response: " + string(response))
-
-	if err != nil {
-		return err
+	fields := []zap.Field{}
+	if resp.Sid != nil {
+		fields = append(fields, zap.String("messageSid", *resp.Sid))
+	}
+	if resp.Status != nil {
+		fields = append(fields, zap.String("status", *resp.Status))
 	}
+	logging.FromContext(ctx).Info("sent sms", fields...)
 
 	return nil
 }
@@ -1,8 +1,10 @@
 package twilio
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
@@ -46,7 +48,7 @@ func TestSendMessageSuccess(t *testing.T) {
 		},
 	}
 
-	err := c.SendMessage("123456", "TEST_MESSAGE")
+	err := c.SendMessage(context.Background(), "123456", "TEST_MESSAGE")
 
 	assert.Nil(err)
 }
@@ -64,7 +66,7 @@ func TestSendMessageFail(t *testing.T) {
 		},
 	}
 
-	err := c.SendMessage("123456", "TEST_MESSAGE")
+	err := c.SendMessage(context.Background(), "123456", "TEST_MESSAGE")
 
 	assert.EqualError(err, "something went wrong!")
 }
@@ -87,7 +89,125 @@ func TestSendMessageErrorCode(t *testing.T) {
 		},
 	}
 
-	err := c.SendMessage("123456", "TEST_MESSAGE")
+	err := c.SendMessage(context.Background(), "123456", "TEST_MESSAGE")
 
 	assert.EqualError(err, "error in Twilio CreateMessage.\n\nError code: 12345\n\nError Message: something else went wrong")
 }
+
+func TestSendMessageContextCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	called := false
+	mockCreateMessage := func(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error) {
+		called = true
+		return &openapi.ApiV2010Message{}, nil
+	}
+
+	c := &fetcher{
+		API: &mockTwilioRestApi{
+			mockCreateMessage: mockCreateMessage,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := c.SendMessage(ctx, "123456", "TEST_MESSAGE")
+
+	assert.ErrorIs(err, context.Canceled)
+	assert.False(called)
+}
+
+func TestSendMessageCancelledMidSend(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockCreateMessage := func(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error) {
+		cancel()
+		return &openapi.ApiV2010Message{}, nil
+	}
+
+	c := &fetcher{
+		API: &mockTwilioRestApi{
+			mockCreateMessage: mockCreateMessage,
+		},
+	}
+
+	// a text that went out is reported as sent
+	err := c.SendMessage(ctx, "123456", "TEST_MESSAGE")
+
+	assert.Nil(err)
+}
+
+func TestWithTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	f := &fetcher{}
+
+	WithTimeout(0)(f)
+	assert.Zero(f.timeout)
+
+	WithTimeout(5 * time.Second)(f)
+	assert.Equal(5*time.Second, f.timeout)
+}
+
+// optOutList is an OptOutChecker over a fixed set of numbers.
+type optOutList struct {
+	phones map[string]bool
+	err    error
+}
+
+func (o *optOutList) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	return o.phones[phone], o.err
+}
+
+func TestSendMessageOptedOut(t *testing.T) {
+	assert := assert.New(t)
+
+	called := false
+	mockCreateMessage := func(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error) {
+		called = true
+		return &openapi.ApiV2010Message{}, nil
+	}
+
+	c := &fetcher{
+		API: &mockTwilioRestApi{
+			mockCreateMessage: mockCreateMessage,
+		},
+	}
+	WithOptOutChecker(&optOutList{phones: map[string]bool{"123456": true}})(c)
+
+	err := c.SendMessage(context.Background(), "123456", "TEST_MESSAGE")
+
+	assert.ErrorIs(err, ErrOptedOut)
+	assert.False(called)
+
+	err = c.SendMessage(context.Background(), "654321", "TEST_MESSAGE")
+
+	assert.Nil(err)
+	assert.True(called)
+}
+
+func TestSendMessageOptOutCheckFail(t *testing.T) {
+	assert := assert.New(t)
+
+	called := false
+	mockCreateMessage := func(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error) {
+		called = true
+		return &openapi.ApiV2010Message{}, nil
+	}
+
+	c := &fetcher{
+		API: &mockTwilioRestApi{
+			mockCreateMessage: mockCreateMessage,
+		},
+		optOuts: &optOutList{err: errors.New("TEST_STORE_ERR")},
+	}
+
+	err := c.SendMessage(context.Background(), "123456", "TEST_MESSAGE")
+
+	assert.EqualError(err, "cannot check opt-outs: TEST_STORE_ERR")
+	assert.False(called)
+}